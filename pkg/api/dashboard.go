@@ -62,25 +62,9 @@ type DashboardResponse struct {
 		ID           int           `json:"id"`
 		Links        []interface{} `json:"links"`
 		Panels       []struct {
-			Datasource  string `json:"datasource"`
-			FieldConfig struct {
-				Defaults struct {
-					Custom struct {
-						Align      interface{} `json:"align"`
-						Filterable bool        `json:"filterable"`
-					} `json:"custom"`
-					Mappings   []interface{} `json:"mappings"`
-					Thresholds struct {
-						Mode  string `json:"mode"`
-						Steps []struct {
-							Color string      `json:"color"`
-							Value interface{} `json:"value"`
-						} `json:"steps"`
-					} `json:"thresholds"`
-				} `json:"defaults"`
-				Overrides []interface{} `json:"overrides"`
-			} `json:"fieldConfig"`
-			GridPos struct {
+			Datasource  string      `json:"datasource"`
+			FieldConfig FieldConfig `json:"fieldConfig"`
+			GridPos     struct {
 				H int `json:"h"`
 				W int `json:"w"`
 				X int `json:"x"`
@@ -88,8 +72,10 @@ type DashboardResponse struct {
 			} `json:"gridPos"`
 			ID      int `json:"id"`
 			Options struct {
-				ShowHeader bool `json:"showHeader"`
-				SortBy     []struct {
+				Orientation string `json:"orientation"`
+				XField      string `json:"xField"`
+				ShowHeader  bool   `json:"showHeader"`
+				SortBy      []struct {
 					Desc        bool   `json:"desc"`
 					DisplayName string `json:"displayName"`
 				} `json:"sortBy"`
@@ -134,6 +120,23 @@ type DashboardResponse struct {
 	} `json:"dashboard"`
 }
 
+// The types of panel which can be reported on. Tables are written as they are, while the data
+// of charts is written along with a native Excel chart.
+const (
+	PanelTypeTable        = "table"
+	PanelTypeMsupplyTable = "msupplyfoundation-table"
+	PanelTypeTimeSeries   = "timeseries"
+	PanelTypeBarChart     = "barchart"
+)
+
+func IsReportablePanel(panelType string) bool {
+	switch panelType {
+	case PanelTypeTable, PanelTypeMsupplyTable, PanelTypeTimeSeries, PanelTypeBarChart:
+		return true
+	}
+	return false
+}
+
 type Dashboard struct {
 	Panels    []TablePanel `json:"panels"`
 	UID       string       `json:"uid"`
@@ -185,8 +188,21 @@ func NewDashboard(authConfig *auth.AuthConfig, uuid string, from string, to stri
 
 	var panels []TablePanel
 	for _, panel := range dashboardResponse.Dashboard.Panels {
-		if panel.Type == "table" || panel.Type == "msupplyfoundation-table" {
+		if IsReportablePanel(panel.Type) {
+			if len(panel.Targets) == 0 {
+				log.DefaultLogger.Warn(fmt.Sprintf("NewDashboard: panel '%s' has no query and is left out", panel.Title))
+				continue
+			}
+
 			newPanel := NewTablePanel(panel.ID, panel.Title, panel.Targets[0].RawSQL, from, to, datasourceID)
+			newPanel.Type = panel.Type
+			newPanel.DashboardUID = dashboardResponse.Dashboard.UID
+			newPanel.FieldConfig = panel.FieldConfig
+			newPanel.Chart = ChartOptions{
+				DrawStyle:   panel.FieldConfig.Defaults.Custom.DrawStyle,
+				Orientation: panel.Options.Orientation,
+				XField:      panel.Options.XField,
+			}
 			panels = append(panels, *newPanel)
 		}
 	}
@@ -210,7 +226,7 @@ func (dashboard *Dashboard) Panel(panelID int) *TablePanel {
 
 func (resp *DashboardResponse) GetRawSQL(panelID int) string {
 	for _, panel := range resp.Dashboard.Panels {
-		if panel.ID == panelID {
+		if panel.ID == panelID && len(panel.Targets) > 0 {
 			return panel.Targets[0].RawSQL
 		}
 	}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// FieldConfig is how a panel shows its fields on the dashboard: the defaults for every field,
+// and the overrides for the fields picked by each override's matcher.
+type FieldConfig struct {
+	Defaults  FieldSettings   `json:"defaults"`
+	Overrides []FieldOverride `json:"overrides"`
+}
+
+// How a table colours its cells by their thresholds and value mappings.
+const (
+	ColorModeBackground = "background"
+	ColorModeText       = "text"
+)
+
+type FieldSettings struct {
+	Custom struct {
+		Align      interface{} `json:"align"`
+		Filterable bool        `json:"filterable"`
+		Hidden     bool        `json:"hidden"`
+		// DisplayMode is how older versions of Grafana show a cell, CellOptions how newer ones do
+		DisplayMode string `json:"displayMode"`
+		CellOptions struct {
+			Type string `json:"type"`
+		} `json:"cellOptions"`
+		// DrawStyle is how a time series is drawn, such as line or bars
+		DrawStyle string `json:"drawStyle"`
+	} `json:"custom"`
+	DisplayName string        `json:"displayName"`
+	Unit        string        `json:"unit"`
+	Decimals    *int          `json:"decimals"`
+	Mappings    []interface{} `json:"mappings"`
+	Thresholds  Thresholds    `json:"thresholds"`
+}
+
+type Thresholds struct {
+	// Mode is absolute, or percentage when the steps are percentages of the range of the values
+	Mode  string          `json:"mode"`
+	Steps []ThresholdStep `json:"steps"`
+}
+
+type ThresholdStep struct {
+	Color string `json:"color"`
+	// Value is nil for the base step, which every value reaches
+	Value *float64 `json:"value"`
+	// Unusable is true when the step's value is not a number, so the step is left out
+	Unusable bool `json:"-"`
+}
+
+// UnmarshalJSON reads a step without failing on a value which is not a number, so one odd
+// step does not lose the whole dashboard. Numbers given as text are read as numbers.
+func (step *ThresholdStep) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Color string      `json:"color"`
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*step = ThresholdStep{Color: raw.Color}
+	switch value := raw.Value.(type) {
+	case nil:
+	case float64:
+		step.Value = &value
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			step.Unusable = true
+			break
+		}
+		step.Value = &number
+	default:
+		step.Unusable = true
+	}
+
+	return nil
+}
+
+type MappingResult struct {
+	Text  string `json:"text"`
+	Color string `json:"color"`
+	Index int    `json:"index"`
+}
+
+// ValueMapping maps values of a field to a text and a colour. A value mapping has a result for
+// each of its Values, while range, regex and special mappings have one Result for the values
+// from From to To, matching Pattern, or matching a special value such as null.
+type ValueMapping struct {
+	Type    string
+	Values  map[string]MappingResult
+	From    *float64
+	To      *float64
+	Pattern string
+	Match   string
+	Result  MappingResult
+}
+
+type FieldMatcher struct {
+	ID      string      `json:"id"`
+	Options interface{} `json:"options"`
+}
+
+type FieldProperty struct {
+	ID    string          `json:"id"`
+	Value json.RawMessage `json:"value"`
+}
+
+type FieldOverride struct {
+	Matcher    FieldMatcher    `json:"matcher"`
+	Properties []FieldProperty `json:"properties"`
+}
+
+// matches reports whether the matcher picks the column. Only the matchers which can be told
+// from a table's columns are known, so fields picked any other way keep the defaults.
+func (matcher FieldMatcher) matches(column Column) bool {
+	option, _ := matcher.Options.(string)
+
+	switch matcher.ID {
+	case "byName":
+		return option == column.Text
+	case "byRegexp":
+		matched, err := regexp.MatchString(option, column.Text)
+		if err != nil {
+			log.DefaultLogger.Error("FieldMatcher.matches: regexp.MatchString: " + err.Error())
+		}
+		return matched
+	case "byType":
+		return option != "" && option == column.Type
+	}
+
+	return false
+}
+
+// apply sets the setting of the property, leaving properties which do not change how a
+// value is written alone.
+func (property FieldProperty) apply(settings *FieldSettings) {
+	var err error
+	switch property.ID {
+	case "displayName":
+		err = json.Unmarshal(property.Value, &settings.DisplayName)
+	case "unit":
+		err = json.Unmarshal(property.Value, &settings.Unit)
+	case "decimals":
+		settings.Decimals = nil
+		err = json.Unmarshal(property.Value, &settings.Decimals)
+	case "custom.hidden":
+		err = json.Unmarshal(property.Value, &settings.Custom.Hidden)
+	case "custom.displayMode":
+		err = json.Unmarshal(property.Value, &settings.Custom.DisplayMode)
+	case "custom.cellOptions":
+		err = json.Unmarshal(property.Value, &settings.Custom.CellOptions)
+	case "thresholds":
+		settings.Thresholds = Thresholds{}
+		err = json.Unmarshal(property.Value, &settings.Thresholds)
+	case "mappings":
+		settings.Mappings = nil
+		err = json.Unmarshal(property.Value, &settings.Mappings)
+	}
+
+	if err != nil {
+		log.DefaultLogger.Error("FieldProperty.apply: " + property.ID + ": " + err.Error())
+	}
+}
+
+// Settings returns the settings of a column: the defaults, with the properties of each
+// override which matches the column applied in order.
+func (config FieldConfig) Settings(column Column) FieldSettings {
+	settings := config.Defaults
+	for _, override := range config.Overrides {
+		if !override.Matcher.matches(column) {
+			continue
+		}
+		for _, property := range override.Properties {
+			property.apply(&settings)
+		}
+	}
+
+	settings.DisplayName = strings.ReplaceAll(settings.DisplayName, "${__field.name}", column.Text)
+	return settings
+}
+
+// ColorMode returns whether the field's cells are coloured by their background or their text,
+// or an empty string when the table does not colour them.
+func (settings FieldSettings) ColorMode() string {
+	mode := settings.Custom.CellOptions.Type
+	if mode == "" {
+		mode = settings.Custom.DisplayMode
+	}
+
+	switch mode {
+	case "color-background", "color-background-solid":
+		return ColorModeBackground
+	case "color-text":
+		return ColorModeText
+	}
+	return ""
+}
+
+// ValueMappings decodes the field's value mappings. Mappings saved by versions of Grafana
+// before 8, which are shaped differently, are left out.
+func (settings FieldSettings) ValueMappings() []ValueMapping {
+	mappings := []ValueMapping{}
+	for _, value := range settings.Mappings {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+
+		var mapping struct {
+			Type    string          `json:"type"`
+			Options json.RawMessage `json:"options"`
+		}
+		if err := json.Unmarshal(raw, &mapping); err != nil || mapping.Type == "" {
+			continue
+		}
+
+		var options struct {
+			From    *float64      `json:"from"`
+			To      *float64      `json:"to"`
+			Pattern string        `json:"pattern"`
+			Match   string        `json:"match"`
+			Result  MappingResult `json:"result"`
+		}
+		valueMapping := ValueMapping{Type: mapping.Type}
+		if mapping.Type == "value" {
+			err = json.Unmarshal(mapping.Options, &valueMapping.Values)
+		} else {
+			err = json.Unmarshal(mapping.Options, &options)
+			valueMapping.From, valueMapping.To = options.From, options.To
+			valueMapping.Pattern, valueMapping.Match = options.Pattern, options.Match
+			valueMapping.Result = options.Result
+		}
+		if err != nil {
+			log.DefaultLogger.Error("FieldSettings.ValueMappings: " + mapping.Type + ": " + err.Error())
+			continue
+		}
+
+		mappings = append(mappings, valueMapping)
+	}
+
+	return mappings
+}
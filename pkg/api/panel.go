@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
@@ -13,6 +14,15 @@ import (
 
 type Column struct {
 	Text string `json:"text"`
+	// Type is the type of the column's values, such as number, string or time
+	Type string `json:"type,omitempty"`
+	// Unit and Decimals are how the dashboard shows the column's values
+	Unit     string `json:"unit,omitempty"`
+	Decimals *int   `json:"decimals,omitempty"`
+	// Thresholds and Mappings colour the column's cells when ColorMode says the dashboard does
+	Thresholds Thresholds     `json:"-"`
+	Mappings   []ValueMapping `json:"-"`
+	ColorMode  string         `json:"-"`
 }
 
 type TablePanel struct {
@@ -25,6 +35,25 @@ type TablePanel struct {
 	Columns      []Column        `json:"columns"`
 	Variables    TemplateList    `json:"variables"`
 	DatasourceID int             `json:"DatasourceID"`
+	// SelectedVariables holds the options of each dashboard variable selected for the report
+	SelectedVariables map[string][]string `json:"-"`
+	// FieldConfig is how the dashboard shows the panel's columns
+	FieldConfig FieldConfig `json:"-"`
+	// DashboardUID is the dashboard the panel is on
+	DashboardUID string `json:"-"`
+	// Type is the type of the panel on the dashboard, a table or a chart
+	Type string `json:"type"`
+	// Chart is how a chart panel draws its data
+	Chart ChartOptions `json:"-"`
+}
+
+type ChartOptions struct {
+	// DrawStyle is line, bars or points for a time series
+	DrawStyle string
+	// Orientation is horizontal or vertical for a bar chart
+	Orientation string
+	// XField is the column a bar chart's bars are labelled by
+	XField string
 }
 
 func NewTablePanel(id int, title string, rawSql string, from string, to string, datasourceID int) *TablePanel {
@@ -35,18 +64,27 @@ func (panel *TablePanel) usesVariable(variable TemplateVariable) bool {
 	return strings.Contains(panel.RawSql, "${"+variable.Name+"}") || strings.Contains(panel.RawSql, "${"+variable.Name+":sqlstring}")
 }
 
+// sqlTimestamp converts a time the panel is queried for into SQL. Times resolved to epoch
+// milliseconds are converted to the seconds to_timestamp expects.
+func sqlTimestamp(value string) string {
+	if milliseconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return "to_timestamp(" + strconv.FormatInt(milliseconds/1000, 10) + ")"
+	}
+	return "to_timestamp(" + value + ")"
+}
+
 func (panel *TablePanel) injectMacros() {
 	usesFrom := strings.Contains(panel.RawSql, "$__timeFrom()")
 	usesTo := strings.Contains(panel.RawSql, "$__timeTo()")
 	usesTimeFilter := strings.Contains(panel.RawSql, "$__timeFilter(")
 
 	if usesFrom {
-		newSql := "to_timestamp(" + panel.To + ")"
+		newSql := sqlTimestamp(panel.To)
 		panel.RawSql = strings.Replace(panel.RawSql, "$__timeTo()", newSql, -1)
 	}
 
 	if usesTo {
-		newSql := "to_timestamp(" + panel.From + ")"
+		newSql := sqlTimestamp(panel.From)
 		panel.RawSql = strings.Replace(panel.RawSql, "$__timeFrom()", newSql, -1)
 	}
 
@@ -59,7 +97,7 @@ func (panel *TablePanel) injectMacros() {
 		columnString := column.FindString(timeFilterString)
 		columnNameString := columnName.FindString(columnString)
 
-		newSql := columnNameString + " BETWEEN " + "to_timestamp(" + panel.From + ") AND " + "to_timestamp(" + panel.To + ")"
+		newSql := columnNameString + " BETWEEN " + sqlTimestamp(panel.From) + " AND " + sqlTimestamp(panel.To)
 		panel.RawSql = timeFilter.ReplaceAllString(panel.RawSql, newSql)
 	}
 
@@ -136,6 +174,12 @@ func (panel *TablePanel) injectVariable(variable TemplateVariable, contentVariab
 
 func (panel *TablePanel) PrepSql(variables TemplateList, contentVariables string) {
 	log.DefaultLogger.Info(contentVariables)
+	// most panels have no variables selected, and nothing to decode
+	if contentVariables != "" {
+		if err := json.Unmarshal([]byte(contentVariables), &panel.SelectedVariables); err != nil {
+			log.DefaultLogger.Error("PrepSql: Decoding JSON: "+contentVariables, err.Error())
+		}
+	}
 	for _, variable := range variables.List {
 		if panel.usesVariable(variable) {
 			panel.injectVariable(variable, contentVariables)
@@ -175,10 +219,52 @@ func (panel *TablePanel) GetData(authConfig auth.AuthConfig) error {
 
 	panel.SetRows(qr.Rows())
 	panel.SetColumns(qr.Columns())
+	if panel.Type == PanelTypeTimeSeries {
+		panel.pivotSeries()
+	}
+	panel.applyFieldConfig()
 
 	return nil
 }
 
+// applyFieldConfig gives the panel's columns the display names, units, decimals, thresholds and
+// value mappings they have on the dashboard, and removes the columns hidden there along with their values.
+func (panel *TablePanel) applyFieldConfig() {
+	columns := []Column{}
+	shown := []int{}
+	for i, column := range panel.Columns {
+		settings := panel.FieldConfig.Settings(column)
+		if settings.Custom.Hidden {
+			continue
+		}
+
+		if settings.DisplayName != "" {
+			column.Text = settings.DisplayName
+		}
+		column.Unit = settings.Unit
+		column.Decimals = settings.Decimals
+		column.Thresholds = settings.Thresholds
+		column.Mappings = settings.ValueMappings()
+		column.ColorMode = settings.ColorMode()
+		columns = append(columns, column)
+		shown = append(shown, i)
+	}
+
+	if len(shown) < len(panel.Columns) {
+		for i, row := range panel.Rows {
+			values := make([]interface{}, len(shown))
+			for j, index := range shown {
+				if index < len(row) {
+					values[j] = row[index]
+				}
+			}
+			panel.Rows[i] = values
+		}
+	}
+
+	panel.Columns = columns
+}
+
 func (panel *TablePanel) SetRows(rows [][]interface{}) {
 	panel.Rows = rows
 }
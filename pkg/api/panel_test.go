@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyFieldConfig(t *testing.T) {
+	var config FieldConfig
+	err := json.Unmarshal([]byte(`{
+		"defaults": {"unit": "short", "decimals": 1, "displayName": "${__field.name} total"},
+		"overrides": [
+			{"matcher": {"id": "byType", "options": "number"}, "properties": [{"id": "unit", "value": "locale"}]},
+			{"matcher": {"id": "byName", "options": "cost"}, "properties": [{"id": "unit", "value": "currencyUSD"}, {"id": "decimals", "value": 2}]},
+			{"matcher": {"id": "byRegexp", "options": "^co"}, "properties": [{"id": "displayName", "value": "Cost"}]},
+			{"matcher": {"id": "byName", "options": "code"}, "properties": [{"id": "custom.hidden", "value": true}]},
+			{"matcher": {"id": "byName", "options": "store"}, "properties": [{"id": "custom.hidden", "value": true}, {"id": "custom.hidden", "value": false}]}
+		]
+	}`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	panel := TablePanel{
+		FieldConfig: config,
+		Columns:     []Column{{Text: "store"}, {Text: "code"}, {Text: "quantity", Type: "number"}, {Text: "cost", Type: "number"}},
+		Rows:        [][]interface{}{{"Central", "A1", 10.0, 2.5}, {"North", "B2", 20.0}},
+	}
+	panel.applyFieldConfig()
+
+	got := []string{}
+	for _, column := range panel.Columns {
+		decimals := "-"
+		if column.Decimals != nil {
+			decimals = fmt.Sprint(*column.Decimals)
+		}
+		got = append(got, strings.Join([]string{column.Text, column.Type, column.Unit, decimals}, "|"))
+	}
+	// the byType, byName and byRegexp overrides apply in order over the defaults
+	want := []string{
+		"store total||short|1",
+		"quantity total|number|locale|1",
+		"Cost|number|currencyUSD|2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columns = %q, want %q", got, want)
+	}
+
+	wantRows := [][]interface{}{{"Central", 10.0, 2.5}, {"North", 20.0, nil}}
+	if !reflect.DeepEqual(panel.Rows, wantRows) {
+		t.Errorf("rows = %v, want %v without the hidden column", panel.Rows, wantRows)
+	}
+}
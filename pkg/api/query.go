@@ -130,6 +130,7 @@ func (qr *QueryResponse) Columns() []Column {
 		for i := range columns {
 			var column Column
 			column.Text = fields[i].Name
+			column.Type = fields[i].Type
 			columns[i] = column
 		}
 		return columns
@@ -0,0 +1,91 @@
+package api
+
+import "strings"
+
+// IsChart reports whether the panel is a chart rather than a table.
+func (panel *TablePanel) IsChart() bool {
+	return panel.Type == PanelTypeTimeSeries || panel.Type == PanelTypeBarChart
+}
+
+// pivotSeries turns a time series queried as a long table, with a row for each time and
+// series, into a wide one with a column for each series, as Grafana does when it draws it.
+// A series is named by the text columns of its rows, along with the name of the value column
+// when there are several. A series with more than one value at a time keeps the last, and
+// tables which are already wide are left alone.
+func (panel *TablePanel) pivotSeries() {
+	timeColumn := -1
+	labelColumns := []int{}
+	valueColumns := []int{}
+	for i, column := range panel.Columns {
+		switch {
+		case column.Type == "time" && timeColumn < 0:
+			timeColumn = i
+		case column.Type == "string":
+			labelColumns = append(labelColumns, i)
+		case column.Type == "number":
+			valueColumns = append(valueColumns, i)
+		}
+	}
+	if timeColumn < 0 || len(labelColumns) == 0 || len(valueColumns) == 0 {
+		return
+	}
+
+	series := []string{}
+	seriesColumn := make(map[string]int)
+	times := []interface{}{}
+	timeRow := make(map[interface{}]int)
+	values := make(map[int]map[int]interface{})
+	for _, row := range panel.Rows {
+		if len(row) != len(panel.Columns) {
+			continue
+		}
+
+		labels := []string{}
+		for _, i := range labelColumns {
+			if label, ok := row[i].(string); ok && label != "" {
+				labels = append(labels, label)
+			}
+		}
+
+		t := row[timeColumn]
+		if _, ok := timeRow[t]; !ok {
+			timeRow[t] = len(times)
+			times = append(times, t)
+		}
+
+		for _, i := range valueColumns {
+			name := strings.Join(labels, " ")
+			if len(valueColumns) > 1 || name == "" {
+				name = strings.TrimSpace(name + " " + panel.Columns[i].Text)
+			}
+
+			column, ok := seriesColumn[name]
+			if !ok {
+				column = len(series)
+				seriesColumn[name] = column
+				series = append(series, name)
+			}
+			if values[timeRow[t]] == nil {
+				values[timeRow[t]] = make(map[int]interface{})
+			}
+			values[timeRow[t]][column] = row[i]
+		}
+	}
+
+	columns := []Column{panel.Columns[timeColumn]}
+	for _, name := range series {
+		columns = append(columns, Column{Text: name, Type: "number"})
+	}
+
+	rows := make([][]interface{}, len(times))
+	for i, t := range times {
+		rows[i] = make([]interface{}, len(columns))
+		rows[i][0] = t
+		for column, value := range values[i] {
+			rows[i][column+1] = value
+		}
+	}
+
+	panel.Columns = columns
+	panel.Rows = rows
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPivotSeries(t *testing.T) {
+	tests := []struct {
+		name        string
+		columns     []Column
+		rows        [][]interface{}
+		wantColumns []string
+		wantRows    [][]interface{}
+	}{
+		{
+			name:    "times and series in the order they are first seen",
+			columns: []Column{{Text: "time", Type: "time"}, {Text: "store", Type: "string"}, {Text: "stock", Type: "number"}},
+			rows: [][]interface{}{
+				{2000.0, "North", 1.0},
+				{1000.0, "Central", 2.0},
+				{1000.0, "North", 3.0},
+				{2000.0, "Central", 4.0},
+			},
+			wantColumns: []string{"time", "North", "Central"},
+			wantRows:    [][]interface{}{{2000.0, 1.0, 4.0}, {1000.0, 3.0, 2.0}},
+		},
+		{
+			name:    "missing cells are left empty",
+			columns: []Column{{Text: "time", Type: "time"}, {Text: "store", Type: "string"}, {Text: "stock", Type: "number"}},
+			rows: [][]interface{}{
+				{1000.0, "Central", 1.0},
+				{2000.0, "North", 2.0},
+				{3000.0, "Central", 3.0},
+			},
+			wantColumns: []string{"time", "Central", "North"},
+			wantRows:    [][]interface{}{{1000.0, 1.0, nil}, {2000.0, nil, 2.0}, {3000.0, 3.0, nil}},
+		},
+		{
+			name:    "the last value of a series at a time is kept",
+			columns: []Column{{Text: "time", Type: "time"}, {Text: "store", Type: "string"}, {Text: "stock", Type: "number"}},
+			rows: [][]interface{}{
+				{1000.0, "Central", 1.0},
+				{1000.0, "Central", 5.0},
+			},
+			wantColumns: []string{"time", "Central"},
+			wantRows:    [][]interface{}{{1000.0, 5.0}},
+		},
+		{
+			name: "several labels and values",
+			columns: []Column{
+				{Text: "time", Type: "time"},
+				{Text: "region", Type: "string"},
+				{Text: "store", Type: "string"},
+				{Text: "stock", Type: "number"},
+				{Text: "orders", Type: "number"},
+			},
+			rows: [][]interface{}{
+				{1000.0, "South", "Central", 1.0, 2.0},
+				{1000.0, "", "North", 3.0, 4.0},
+			},
+			wantColumns: []string{"time", "South Central stock", "South Central orders", "North stock", "North orders"},
+			wantRows:    [][]interface{}{{1000.0, 1.0, 2.0, 3.0, 4.0}},
+		},
+		{
+			name:    "rows of the wrong length are left out",
+			columns: []Column{{Text: "time", Type: "time"}, {Text: "store", Type: "string"}, {Text: "stock", Type: "number"}},
+			rows: [][]interface{}{
+				{1000.0, "Central"},
+				{2000.0, "Central", 2.0},
+			},
+			wantColumns: []string{"time", "Central"},
+			wantRows:    [][]interface{}{{2000.0, 2.0}},
+		},
+		{
+			name:        "wide tables are left alone",
+			columns:     []Column{{Text: "time", Type: "time"}, {Text: "Central", Type: "number"}, {Text: "North", Type: "number"}},
+			rows:        [][]interface{}{{1000.0, 1.0, 2.0}},
+			wantColumns: []string{"time", "Central", "North"},
+			wantRows:    [][]interface{}{{1000.0, 1.0, 2.0}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			panel := TablePanel{Columns: test.columns, Rows: test.rows}
+			panel.pivotSeries()
+
+			columns := []string{}
+			for _, column := range panel.Columns {
+				columns = append(columns, column.Text)
+			}
+			if !reflect.DeepEqual(columns, test.wantColumns) {
+				t.Errorf("columns = %q, want %q", columns, test.wantColumns)
+			}
+			if !reflect.DeepEqual(panel.Rows, test.wantRows) {
+				t.Errorf("rows = %v, want %v", panel.Rows, test.wantRows)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var RELATIVE_TIME_STEP_REG = regexp.MustCompile(`^([+-])(\d*)([smhdwMy])`)
+
+// RELATIVE_LOOKBACK_REG matches the rolling lookbacks a panel can have, such as "now-7d".
+var RELATIVE_LOOKBACK_REG = regexp.MustCompile(`^now-\d+[smhdwMy]$`)
+
+// ResolveTimeRange resolves the Grafana relative times from and to, such as "now-7d" or
+// "now-1M/M", against reference and returns them as epoch milliseconds. A rounded from is
+// rounded down to the start of its unit and a rounded to up to the end of it.
+func ResolveTimeRange(from string, to string, reference time.Time) (string, string, error) {
+	resolvedFrom, err := ResolveTime(from, reference, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	resolvedTo, err := ResolveTime(to, reference, true)
+	if err != nil {
+		return "", "", err
+	}
+
+	return resolvedFrom, resolvedTo, nil
+}
+
+// ResolveTime resolves a single Grafana relative time against reference and returns it as
+// epoch milliseconds. Values which do not start with "now" are returned unchanged.
+func ResolveTime(value string, reference time.Time, roundUp bool) (string, error) {
+	if !strings.HasPrefix(value, "now") {
+		return value, nil
+	}
+
+	t := reference
+	rest := strings.TrimPrefix(value, "now")
+	for rest != "" {
+		if strings.HasPrefix(rest, "/") {
+			unit := strings.TrimPrefix(rest, "/")
+			if len(unit) != 1 || !strings.Contains("smhdwMy", unit) {
+				return "", fmt.Errorf("invalid relative time: %s", value)
+			}
+			t = roundTime(t, unit[0], roundUp)
+			break
+		}
+
+		step := RELATIVE_TIME_STEP_REG.FindStringSubmatch(rest)
+		if step == nil {
+			return "", fmt.Errorf("invalid relative time: %s", value)
+		}
+
+		amount := 1
+		if step[2] != "" {
+			amount, _ = strconv.Atoi(step[2])
+		}
+		if step[1] == "-" {
+			amount = -amount
+		}
+
+		t = addTime(t, step[3][0], amount)
+		rest = rest[len(step[0]):]
+	}
+
+	return formatMilliseconds(t), nil
+}
+
+// ParseTime returns the time of a panel's from or to, which is either epoch milliseconds or a
+// Grafana relative time resolved against reference.
+func ParseTime(value string, reference time.Time, roundUp bool) (time.Time, error) {
+	resolved, err := ResolveTime(value, reference, roundUp)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	milliseconds, err := strconv.ParseInt(resolved, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", value)
+	}
+
+	return time.Unix(0, milliseconds*int64(time.Millisecond)), nil
+}
+
+func addTime(t time.Time, unit byte, amount int) time.Time {
+	switch unit {
+	case 's':
+		return t.Add(time.Duration(amount) * time.Second)
+	case 'm':
+		return t.Add(time.Duration(amount) * time.Minute)
+	case 'h':
+		return t.Add(time.Duration(amount) * time.Hour)
+	case 'd':
+		return t.AddDate(0, 0, amount)
+	case 'w':
+		return t.AddDate(0, 0, 7*amount)
+	case 'M':
+		return t.AddDate(0, amount, 0)
+	default: // 'y'
+		return t.AddDate(amount, 0, 0)
+	}
+}
+
+// roundTime rounds t down to the start of unit, or up to the last millisecond of it. Weeks
+// start on Sunday, as they do in Grafana by default.
+func roundTime(t time.Time, unit byte, roundUp bool) time.Time {
+	var start time.Time
+	switch unit {
+	case 's':
+		start = t.Truncate(time.Second)
+	case 'm':
+		start = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	case 'h':
+		start = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case 'd':
+		start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case 'w':
+		start = time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, t.Location())
+	case 'M':
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default: // 'y'
+		start = time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+
+	if !roundUp {
+		return start
+	}
+
+	return addTime(start, unit, 1).Add(-time.Millisecond)
+}
+
+// Calendar-aligned lookbacks cover whole calendar periods before the time a report is for,
+// rather than a rolling window ending at it.
+const (
+	LookbackPreviousDay        = "previousDay"
+	LookbackPreviousWeek       = "previousWeek"
+	LookbackPreviousMonth      = "previousMonth"
+	LookbackPreviousQuarter    = "previousQuarter"
+	LookbackPreviousYear       = "previousYear"
+	LookbackMonthToDate        = "monthToDate"
+	LookbackPreviousFiscalYear = "previousFiscalYear"
+	LookbackFiscalYearToDate   = "fiscalYearToDate"
+)
+
+func IsCalendarLookback(lookback string) bool {
+	switch lookback {
+	case LookbackPreviousDay, LookbackPreviousWeek, LookbackPreviousMonth, LookbackPreviousQuarter, LookbackPreviousYear,
+		LookbackMonthToDate, LookbackPreviousFiscalYear, LookbackFiscalYearToDate:
+		return true
+	}
+	return false
+}
+
+// IsRelativeLookback reports whether lookback is a rolling lookback ending now, such as "now-7d".
+func IsRelativeLookback(lookback string) bool {
+	return RELATIVE_LOOKBACK_REG.MatchString(lookback)
+}
+
+// CalendarTimeRange returns the from and to times, as epoch milliseconds, of a calendar-aligned
+// lookback for a report at reference. Periods are worked out in reference's time zone, weeks
+// start on Monday and fiscal years start on the first of fiscalYearStartMonth (1 to 12, with 0
+// meaning January). Periods before reference end on the last millisecond before the current one starts.
+func CalendarTimeRange(lookback string, reference time.Time, fiscalYearStartMonth int) (string, string, error) {
+	location := reference.Location()
+	year, month, day := reference.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, location)
+
+	if fiscalYearStartMonth < 1 || fiscalYearStartMonth > 12 {
+		fiscalYearStartMonth = 1
+	}
+	fiscalYearStart := time.Date(year, time.Month(fiscalYearStartMonth), 1, 0, 0, 0, 0, location)
+	if fiscalYearStart.After(reference) {
+		fiscalYearStart = fiscalYearStart.AddDate(-1, 0, 0)
+	}
+
+	var from, to time.Time
+	switch lookback {
+	case LookbackPreviousDay:
+		from, to = today.AddDate(0, 0, -1), today
+	case LookbackPreviousWeek:
+		weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		from, to = weekStart.AddDate(0, 0, -7), weekStart
+	case LookbackPreviousMonth:
+		monthStart := time.Date(year, month, 1, 0, 0, 0, 0, location)
+		from, to = monthStart.AddDate(0, -1, 0), monthStart
+	case LookbackPreviousQuarter:
+		quarterStart := time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, location)
+		from, to = quarterStart.AddDate(0, -3, 0), quarterStart
+	case LookbackPreviousYear:
+		yearStart := time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+		from, to = yearStart.AddDate(-1, 0, 0), yearStart
+	case LookbackMonthToDate:
+		from = time.Date(year, month, 1, 0, 0, 0, 0, location)
+		return formatMilliseconds(from), formatMilliseconds(reference), nil
+	case LookbackPreviousFiscalYear:
+		from, to = fiscalYearStart.AddDate(-1, 0, 0), fiscalYearStart
+	case LookbackFiscalYearToDate:
+		return formatMilliseconds(fiscalYearStart), formatMilliseconds(reference), nil
+	default:
+		return "", "", fmt.Errorf("unknown lookback: %s", lookback)
+	}
+
+	return formatMilliseconds(from), formatMilliseconds(to.Add(-time.Millisecond)), nil
+}
+
+func formatMilliseconds(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testTimeLayout = "2006-01-02 15:04:05.000"
+
+// milliseconds returns the epoch milliseconds of a time formatted as testTimeLayout in location.
+func milliseconds(t *testing.T, location *time.Location, value string) string {
+	t.Helper()
+	parsed, err := time.ParseInLocation(testTimeLayout, value, location)
+	if err != nil {
+		t.Fatalf("ParseInLocation(%q): %v", value, err)
+	}
+	return strconv.FormatInt(parsed.UnixMilli(), 10)
+}
+
+func TestCalendarTimeRange(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                 string
+		lookback             string
+		reference            string
+		location             *time.Location
+		fiscalYearStartMonth int
+		from                 string
+		to                   string
+	}{
+		{
+			name:      "previous day",
+			lookback:  LookbackPreviousDay,
+			reference: "2024-05-15 10:00:00.000",
+			from:      "2024-05-14 00:00:00.000",
+			to:        "2024-05-14 23:59:59.999",
+		},
+		{
+			name:      "previous day across the end of a year",
+			lookback:  LookbackPreviousDay,
+			reference: "2025-01-01 00:30:00.000",
+			from:      "2024-12-31 00:00:00.000",
+			to:        "2024-12-31 23:59:59.999",
+		},
+		{
+			name:      "previous week starts on Monday",
+			lookback:  LookbackPreviousWeek,
+			reference: "2024-05-15 10:00:00.000",
+			from:      "2024-05-06 00:00:00.000",
+			to:        "2024-05-12 23:59:59.999",
+		},
+		{
+			name:      "previous week on a Sunday",
+			lookback:  LookbackPreviousWeek,
+			reference: "2024-05-19 10:00:00.000",
+			from:      "2024-05-06 00:00:00.000",
+			to:        "2024-05-12 23:59:59.999",
+		},
+		{
+			name:      "previous month",
+			lookback:  LookbackPreviousMonth,
+			reference: "2024-03-31 10:00:00.000",
+			from:      "2024-02-01 00:00:00.000",
+			to:        "2024-02-29 23:59:59.999",
+		},
+		{
+			name:      "previous quarter",
+			lookback:  LookbackPreviousQuarter,
+			reference: "2024-05-15 10:00:00.000",
+			from:      "2024-01-01 00:00:00.000",
+			to:        "2024-03-31 23:59:59.999",
+		},
+		{
+			name:      "previous quarter in the first quarter",
+			lookback:  LookbackPreviousQuarter,
+			reference: "2024-02-15 10:00:00.000",
+			from:      "2023-10-01 00:00:00.000",
+			to:        "2023-12-31 23:59:59.999",
+		},
+		{
+			name:      "previous year",
+			lookback:  LookbackPreviousYear,
+			reference: "2024-05-15 10:00:00.000",
+			from:      "2023-01-01 00:00:00.000",
+			to:        "2023-12-31 23:59:59.999",
+		},
+		{
+			name:      "month to date ends at the reference",
+			lookback:  LookbackMonthToDate,
+			reference: "2024-05-15 10:00:00.000",
+			from:      "2024-05-01 00:00:00.000",
+			to:        "2024-05-15 10:00:00.000",
+		},
+		{
+			name:                 "previous fiscal year starting in July",
+			lookback:             LookbackPreviousFiscalYear,
+			reference:            "2024-05-15 10:00:00.000",
+			fiscalYearStartMonth: 7,
+			from:                 "2022-07-01 00:00:00.000",
+			to:                   "2023-06-30 23:59:59.999",
+		},
+		{
+			name:                 "previous fiscal year on its first day",
+			lookback:             LookbackPreviousFiscalYear,
+			reference:            "2024-07-01 00:00:00.000",
+			fiscalYearStartMonth: 7,
+			from:                 "2023-07-01 00:00:00.000",
+			to:                   "2024-06-30 23:59:59.999",
+		},
+		{
+			name:                 "fiscal year starting in month 0 is a calendar year",
+			lookback:             LookbackPreviousFiscalYear,
+			reference:            "2024-05-15 10:00:00.000",
+			fiscalYearStartMonth: 0,
+			from:                 "2023-01-01 00:00:00.000",
+			to:                   "2023-12-31 23:59:59.999",
+		},
+		{
+			name:                 "fiscal year to date starting in April",
+			lookback:             LookbackFiscalYearToDate,
+			reference:            "2024-05-15 10:00:00.000",
+			fiscalYearStartMonth: 4,
+			from:                 "2024-04-01 00:00:00.000",
+			to:                   "2024-05-15 10:00:00.000",
+		},
+		{
+			name:      "periods follow the reference's time zone",
+			lookback:  LookbackPreviousMonth,
+			reference: "2024-04-01 08:00:00.000",
+			location:  auckland,
+			from:      "2024-03-01 00:00:00.000",
+			to:        "2024-03-31 23:59:59.999",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			location := test.location
+			if location == nil {
+				location = time.UTC
+			}
+			reference, err := time.ParseInLocation(testTimeLayout, test.reference, location)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			from, to, err := CalendarTimeRange(test.lookback, reference, test.fiscalYearStartMonth)
+			if err != nil {
+				t.Fatalf("CalendarTimeRange returned an error: %v", err)
+			}
+			if want := milliseconds(t, location, test.from); from != want {
+				t.Errorf("from = %s, want %s (%s)", from, want, test.from)
+			}
+			if want := milliseconds(t, location, test.to); to != want {
+				t.Errorf("to = %s, want %s (%s)", to, want, test.to)
+			}
+		})
+	}
+}
+
+func TestCalendarTimeRangeUnknownLookback(t *testing.T) {
+	if _, _, err := CalendarTimeRange("previousMnth", time.Now(), 0); err == nil {
+		t.Error("CalendarTimeRange accepted an unknown lookback")
+	}
+}
+
+func TestResolveTime(t *testing.T) {
+	reference := time.Date(2024, 5, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		roundUp bool
+		want    string
+		wantErr bool
+	}{
+		{value: "now", want: "2024-05-15 10:30:00.000"},
+		{value: "now-7d", want: "2024-05-08 10:30:00.000"},
+		{value: "now-1M", want: "2024-04-15 10:30:00.000"},
+		{value: "now-1d/d", want: "2024-05-14 00:00:00.000"},
+		{value: "now-1d/d", roundUp: true, want: "2024-05-14 23:59:59.999"},
+		{value: "now/M", want: "2024-05-01 00:00:00.000"},
+		{value: "now-7x", wantErr: true},
+		{value: "now/q", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ResolveTime(test.value, reference, test.roundUp)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("ResolveTime(%q) = %s, want an error", test.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveTime(%q) returned an error: %v", test.value, err)
+			}
+			if want := milliseconds(t, time.UTC, test.want); got != want {
+				t.Errorf("ResolveTime(%q) = %s, want %s (%s)", test.value, got, want, test.want)
+			}
+		})
+	}
+}
+
+func TestLookbackKinds(t *testing.T) {
+	tests := []struct {
+		lookback string
+		calendar bool
+		relative bool
+	}{
+		{lookback: "previousMonth", calendar: true},
+		{lookback: "fiscalYearToDate", calendar: true},
+		{lookback: "now-7d", relative: true},
+		{lookback: "now-12M", relative: true},
+		{lookback: "previousMnth"},
+		{lookback: "bogus"},
+		{lookback: "now"},
+		{lookback: "now-7"},
+		{lookback: "now-7d/d"},
+		{lookback: "now+1d"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.lookback, func(t *testing.T) {
+			if got := IsCalendarLookback(test.lookback); got != test.calendar {
+				t.Errorf("IsCalendarLookback(%q) = %v, want %v", test.lookback, got, test.calendar)
+			}
+			if got := IsRelativeLookback(test.lookback); got != test.relative {
+				t.Errorf("IsRelativeLookback(%q) = %v, want %v", test.lookback, got, test.relative)
+			}
+		})
+	}
+}
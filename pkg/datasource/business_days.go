@@ -0,0 +1,148 @@
+package datasource
+
+import (
+	"time"
+)
+
+const (
+	// BusinessDayRuleNext moves a report time which is not on a business day to the next business day
+	BusinessDayRuleNext = "next"
+	// BusinessDayRulePrevious moves a report time which is not on a business day to the business day before it
+	BusinessDayRulePrevious = "previous"
+	// BusinessDayRuleSkip drops report times which are not on a business day
+	BusinessDayRuleSkip = "skip"
+)
+
+// INTERVAL_BUSINESS_DAY_OF_MONTH fires on the Day'th business day of each month.
+const INTERVAL_BUSINESS_DAY_OF_MONTH = 6
+
+// maxBusinessDaySearch limits how many report times are looked through for one on a business day.
+const maxBusinessDaySearch = 1000
+
+func (schedule *Schedule) SetHolidays(holidays []Holiday) {
+	schedule.holidays = make(map[string]bool)
+	for _, holiday := range holidays {
+		schedule.holidays[holiday.Date] = true
+	}
+}
+
+// IsBusinessDay reports whether the day of t, in the schedule's time zone, is a weekday which
+// is not a holiday in the schedule's calendar.
+func (schedule *Schedule) IsBusinessDay(t time.Time) bool {
+	t = t.In(schedule.Location())
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+
+	return !schedule.holidays[t.Format(holidayDateLayout)]
+}
+
+// NextReportTimeAfter returns the first time the schedule should fire after now, with its
+// business day rule applied.
+func (schedule *Schedule) NextReportTimeAfter(now time.Time) time.Time {
+	if schedule.CronExpression == "" && schedule.Interval == INTERVAL_BUSINESS_DAY_OF_MONTH {
+		return schedule.businessDayOfMonthAfter(now)
+	}
+
+	return schedule.businessDayReportTime(schedule.intervalReportTimeAfter(now), now)
+}
+
+// businessDayReportTime applies the schedule's business day rule to one of its report times,
+// looking through the report times after it when the rule skips it. A report time moved back
+// to before now has already been sent.
+func (schedule *Schedule) businessDayReportTime(reportTime time.Time, now time.Time) time.Time {
+	for i := 0; i < maxBusinessDaySearch && !reportTime.IsZero(); i++ {
+		if schedule.IsBusinessDay(reportTime) {
+			return reportTime
+		}
+
+		switch schedule.BusinessDayRule {
+		case BusinessDayRuleNext:
+			return schedule.shiftToBusinessDay(reportTime, 1)
+		case BusinessDayRulePrevious:
+			if shifted := schedule.shiftToBusinessDay(reportTime, -1); shifted.After(now) {
+				return shifted
+			}
+		case BusinessDayRuleSkip:
+		default:
+			return reportTime
+		}
+
+		reportTime = schedule.intervalReportTimeAfter(reportTime.Add(time.Second))
+	}
+
+	return reportTime
+}
+
+// RefreshNextReportTime checks the schedule's next report time again after the holidays of its
+// calendar have changed. A report time which is still on a business day is kept, so nothing
+// waiting to be sent is dropped; one which is not is moved as the business day rule says. A
+// business day of the month is worked out again within the month of the report time.
+func (schedule *Schedule) RefreshNextReportTime(now time.Time) {
+	if schedule.NextReportTime <= 0 {
+		return
+	}
+
+	reportTime := time.Unix(int64(schedule.NextReportTime), 0).In(schedule.Location())
+	if schedule.CronExpression == "" && schedule.Interval == INTERVAL_BUSINESS_DAY_OF_MONTH {
+		from := time.Date(reportTime.Year(), reportTime.Month(), 1, 0, 0, 0, 0, reportTime.Location()).Add(-time.Second)
+		if from.Before(now) {
+			from = now
+		}
+		reportTime = schedule.businessDayOfMonthAfter(from)
+	} else if !schedule.IsBusinessDay(reportTime) {
+		reportTime = schedule.businessDayReportTime(reportTime, now)
+	}
+
+	if !reportTime.IsZero() {
+		schedule.NextReportTime = int(reportTime.Unix())
+	}
+}
+
+// shiftToBusinessDay moves t a day at a time in the direction of step, keeping its time of
+// day, until it falls on a business day.
+func (schedule *Schedule) shiftToBusinessDay(t time.Time, step int) time.Time {
+	t = t.In(schedule.Location())
+	for i := 0; i < maxBusinessDaySearch && !schedule.IsBusinessDay(t); i++ {
+		t = t.AddDate(0, 0, step)
+	}
+
+	return t
+}
+
+// businessDayOfMonthAfter returns the first time after now which is on the Day'th business
+// day of its month, at the schedule's Time. Months with fewer business days use their last one.
+func (schedule *Schedule) businessDayOfMonthAfter(now time.Time) time.Time {
+	location := schedule.Location()
+	now = now.In(location)
+
+	hour, minute := 0, 0
+	if timeOfDay, err := time.Parse("15:04", schedule.Time); err == nil {
+		hour, minute = timeOfDay.Hour(), timeOfDay.Minute()
+	}
+
+	n := schedule.Day
+	if n < 1 {
+		n = 1
+	}
+
+	for months := 0; months < 24; months++ {
+		first := time.Date(now.Year(), now.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+
+		var reportTime time.Time
+		count := 0
+		for day := first; day.Month() == first.Month() && count < n; day = day.AddDate(0, 0, 1) {
+			candidate := wallClockIn(time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, time.UTC), location)
+			if schedule.IsBusinessDay(candidate) {
+				reportTime = candidate
+				count++
+			}
+		}
+
+		if !reportTime.IsZero() && reportTime.After(now) {
+			return reportTime
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,175 @@
+package datasource
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNextReportTimeAfterOnBusinessDays(t *testing.T) {
+	christmas := []Holiday{{Date: "2024-12-25", Name: "Christmas Day"}}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		holidays []Holiday
+		now      string
+		want     string
+	}{
+		{
+			name:     "next moves a holiday to the day after",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC", BusinessDayRule: BusinessDayRuleNext},
+			holidays: christmas,
+			now:      "2024-12-24 09:00",
+			want:     "2024-12-26 08:00",
+		},
+		{
+			name:     "next moves a Saturday to Monday",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC", BusinessDayRule: BusinessDayRuleNext},
+			now:      "2024-12-27 09:00",
+			want:     "2024-12-30 08:00",
+		},
+		{
+			name:     "previous moves a holiday to the day before",
+			schedule: Schedule{Interval: 1, Day: 3, Time: "08:00", TimeZone: "UTC", BusinessDayRule: BusinessDayRulePrevious},
+			holidays: christmas,
+			now:      "2024-12-23 09:00",
+			want:     "2024-12-24 08:00",
+		},
+		{
+			name:     "previous skips a day before which has already passed",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC", BusinessDayRule: BusinessDayRulePrevious},
+			holidays: christmas,
+			now:      "2024-12-24 09:00",
+			want:     "2024-12-26 08:00",
+		},
+		{
+			name:     "skip leaves out a holiday",
+			schedule: Schedule{Interval: 1, Day: 3, Time: "08:00", TimeZone: "UTC", BusinessDayRule: BusinessDayRuleSkip},
+			holidays: christmas,
+			now:      "2024-12-23 09:00",
+			want:     "2025-01-01 08:00",
+		},
+		{
+			name:     "no rule sends on a holiday",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC"},
+			holidays: christmas,
+			now:      "2024-12-24 09:00",
+			want:     "2024-12-25 08:00",
+		},
+		{
+			name:     "cron with next",
+			schedule: Schedule{CronExpression: "0 7 * * *", TimeZone: "UTC", BusinessDayRule: BusinessDayRuleNext},
+			holidays: christmas,
+			now:      "2024-12-24 09:00",
+			want:     "2024-12-26 07:00",
+		},
+		{
+			name:     "third business day of the month",
+			schedule: Schedule{Interval: INTERVAL_BUSINESS_DAY_OF_MONTH, Day: 3, Time: "09:00", TimeZone: "UTC"},
+			now:      "2024-12-01 00:00",
+			want:     "2024-12-04 09:00",
+		},
+		{
+			name:     "business day of the month skips holidays",
+			schedule: Schedule{Interval: INTERVAL_BUSINESS_DAY_OF_MONTH, Day: 3, Time: "09:00", TimeZone: "UTC"},
+			holidays: []Holiday{{Date: "2024-12-03"}},
+			now:      "2024-12-01 00:00",
+			want:     "2024-12-05 09:00",
+		},
+		{
+			name:     "business day of the month past this month's moves to the next month",
+			schedule: Schedule{Interval: INTERVAL_BUSINESS_DAY_OF_MONTH, Day: 1, Time: "09:00", TimeZone: "UTC"},
+			now:      "2024-12-02 10:00",
+			want:     "2025-01-01 09:00",
+		},
+		{
+			name:     "a month with fewer business days uses its last",
+			schedule: Schedule{Interval: INTERVAL_BUSINESS_DAY_OF_MONTH, Day: 23, Time: "09:00", TimeZone: "UTC"},
+			now:      "2025-02-01 00:00",
+			want:     "2025-02-28 09:00",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.schedule.SetHolidays(test.holidays)
+			got := test.schedule.NextReportTimeAfter(inZone(t, "UTC", test.now))
+			if local := got.UTC().Format(testTimeLayout); local != test.want {
+				t.Errorf("NextReportTimeAfter(%s) = %s, want %s", test.now, local, test.want)
+			}
+		})
+	}
+}
+
+func TestIsBusinessDayInTimeZone(t *testing.T) {
+	schedule := Schedule{TimeZone: "Pacific/Auckland"}
+	schedule.SetHolidays([]Holiday{{Date: "2024-12-25"}})
+
+	// 20:00 UTC on Christmas Eve is already Christmas Day in Auckland
+	if schedule.IsBusinessDay(inZone(t, "UTC", "2024-12-24 20:00")) {
+		t.Error("IsBusinessDay is true on a holiday in the schedule's time zone")
+	}
+	if !schedule.IsBusinessDay(inZone(t, "UTC", "2024-12-24 00:00")) {
+		t.Error("IsBusinessDay is false on a weekday which is not a holiday")
+	}
+}
+
+func TestRefreshNextReportTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		holidays []Holiday
+		next     string
+		now      string
+		want     string
+	}{
+		{
+			name:     "a report time on a new holiday moves",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC", BusinessDayRule: BusinessDayRuleNext},
+			holidays: []Holiday{{Date: "2024-12-25"}},
+			next:     "2024-12-25 08:00",
+			now:      "2024-12-24 09:00",
+			want:     "2024-12-26 08:00",
+		},
+		{
+			name:     "a report time still on a business day is kept",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC", BusinessDayRule: BusinessDayRuleNext},
+			next:     "2024-12-26 08:00",
+			now:      "2024-12-24 09:00",
+			want:     "2024-12-26 08:00",
+		},
+		{
+			name:     "a business day of the month is worked out again in its month",
+			schedule: Schedule{Interval: INTERVAL_BUSINESS_DAY_OF_MONTH, Day: 3, Time: "09:00", TimeZone: "UTC"},
+			holidays: []Holiday{{Date: "2024-12-03"}},
+			next:     "2024-12-04 09:00",
+			now:      "2024-12-01 00:00",
+			want:     "2024-12-05 09:00",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.schedule.SetHolidays(test.holidays)
+			test.schedule.NextReportTime = int(inZone(t, "UTC", test.next).Unix())
+			test.schedule.RefreshNextReportTime(inZone(t, "UTC", test.now))
+			if want := int(inZone(t, "UTC", test.want).Unix()); test.schedule.NextReportTime != want {
+				t.Errorf("RefreshNextReportTime moved %s to %d, want %s", test.next, test.schedule.NextReportTime, test.want)
+			}
+		})
+	}
+}
+
+func TestUpcomingReportTimesOnBusinessDays(t *testing.T) {
+	schedule := Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC", BusinessDayRule: BusinessDayRuleNext}
+	schedule.SetHolidays([]Holiday{{Date: "2024-12-25"}, {Date: "2024-12-26"}})
+
+	want := []string{"2024-12-24 08:00", "2024-12-27 08:00", "2024-12-30 08:00"}
+	got := []string{}
+	for _, reportTime := range schedule.UpcomingReportTimes(inZone(t, "UTC", "2024-12-23 09:00"), len(want)) {
+		got = append(got, reportTime.Format(testTimeLayout))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UpcomingReportTimes = %v, want %v", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package datasource
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -25,6 +26,38 @@ type MsupplyEresDatasourceInstance struct {
 	logger log.Logger
 }
 
+type columnMigration struct {
+	table      string
+	column     string
+	definition string
+}
+
+// Columns added after a table was first released. They are applied on start up so that
+// databases created by earlier versions of the plugin keep their existing rows.
+var columnMigrations = []columnMigration{
+	{"Schedule", "cronExpression", "TEXT NOT NULL DEFAULT ''"},
+	{"Schedule", "timeZone", "TEXT NOT NULL DEFAULT ''"},
+	{"Config", "reportWorkers", "INTEGER NOT NULL DEFAULT 0"},
+	{"Schedule", "retryMaxAttempts", "INTEGER NOT NULL DEFAULT 0"},
+	{"Schedule", "retryBackoffSeconds", "INTEGER NOT NULL DEFAULT 0"},
+	{"Schedule", "retryAfter", "INTEGER NOT NULL DEFAULT 0"},
+	{"ScheduleRun", "scheduledTime", "INTEGER NOT NULL DEFAULT 0"},
+	{"ScheduleRun", "attempt", "INTEGER NOT NULL DEFAULT 1"},
+	{"ScheduleRun", "deliveredTo", "TEXT NOT NULL DEFAULT '[]'"},
+	{"Schedule", "catchUpPolicy", "TEXT NOT NULL DEFAULT ''"},
+	{"Schedule", "enabled", "INTEGER NOT NULL DEFAULT 1"},
+	{"Schedule", "startDate", "INTEGER NOT NULL DEFAULT 0"},
+	{"Schedule", "endDate", "INTEGER NOT NULL DEFAULT 0"},
+	{"Schedule", "holidayCalendarID", "TEXT NOT NULL DEFAULT ''"},
+	{"Schedule", "businessDayRule", "TEXT NOT NULL DEFAULT ''"},
+	{"Schedule", "fiscalYearStartMonth", "INTEGER NOT NULL DEFAULT 0"},
+	{"Schedule", "format", "TEXT NOT NULL DEFAULT ''"},
+	{"Schedule", "inlineRows", "INTEGER NOT NULL DEFAULT 0"},
+	{"Schedule", "templateID", "TEXT NOT NULL DEFAULT ''"},
+	{"Schedule", "summarySheet", "INTEGER NOT NULL DEFAULT 0"},
+	{"Schedule", "layout", "TEXT NOT NULL DEFAULT ''"},
+}
+
 func NewMsupplyEresDatasource() (*MsupplyEresDatasource, error) {
 	var runningOS string
 	var dataPath string
@@ -180,12 +213,70 @@ func (datasource *MsupplyEresDatasource) Init() (*bool, error) {
 		return nil, err
 	}
 
+	stmt, err = sqlClient.Db.Prepare("CREATE TABLE IF NOT EXISTS ScheduleRun (id TEXT PRIMARY KEY, scheduleID TEXT, startTime INTEGER, endTime INTEGER, status TEXT, panelRowCounts TEXT, recipientsAttempted INTEGER, recipientsSucceeded INTEGER, error TEXT, FOREIGN KEY(scheduleID) REFERENCES Schedule(id))")
+	if err != nil {
+		err = fmt.Errorf("FATAL. Could not create ScheduleRun: %w", err)
+		return nil, err
+	}
+	stmt.Exec()
+
+	stmt, err = sqlClient.Db.Prepare("CREATE TABLE IF NOT EXISTS HolidayCalendar (id TEXT PRIMARY KEY, name TEXT)")
+	if err != nil {
+		err = fmt.Errorf("FATAL. Could not create HolidayCalendar: %w", err)
+		return nil, err
+	}
+	stmt.Exec()
+
+	stmt, err = sqlClient.Db.Prepare("CREATE TABLE IF NOT EXISTS Holiday (holidayCalendarID TEXT, date TEXT, name TEXT, PRIMARY KEY(holidayCalendarID, date), FOREIGN KEY(holidayCalendarID) REFERENCES HolidayCalendar(id))")
+	if err != nil {
+		err = fmt.Errorf("FATAL. Could not create Holiday: %w", err)
+		return nil, err
+	}
+	stmt.Exec()
+
+	stmt, err = sqlClient.Db.Prepare("CREATE TABLE IF NOT EXISTS ScheduleDependency (scheduleID TEXT, dependsOnID TEXT, PRIMARY KEY(scheduleID, dependsOnID), FOREIGN KEY(scheduleID) REFERENCES Schedule(id), FOREIGN KEY(dependsOnID) REFERENCES Schedule(id))")
+	if err != nil {
+		err = fmt.Errorf("FATAL. Could not create ScheduleDependency: %w", err)
+		return nil, err
+	}
+	stmt.Exec()
+
+	stmt, err = sqlClient.Db.Prepare("CREATE TABLE IF NOT EXISTS Template (id TEXT PRIMARY KEY, name TEXT, fileName TEXT, uploadedAt INTEGER)")
+	if err != nil {
+		err = fmt.Errorf("FATAL. Could not create Template: %w", err)
+		return nil, err
+	}
+	stmt.Exec()
+
+	for _, migration := range columnMigrations {
+		err = addColumnIfNotExists(sqlClient.Db, migration.table, migration.column, migration.definition)
+		if err != nil {
+			err = fmt.Errorf("FATAL. Could not add %s.%s: %w", migration.table, migration.column, err)
+			return nil, err
+		}
+	}
+
 	datasource.logger.Info("Database initialized!")
 
 	status := true
 	return &status, nil
 }
 
+func addColumnIfNotExists(db *sql.DB, table string, column string, definition string) error {
+	var count int
+	row := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column)
+	if err := row.Scan(&count); err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	_, err := db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
 func trace() *runtime.Frame {
 	pc := make([]uintptr, 15)
 	n := runtime.Callers(2, pc)
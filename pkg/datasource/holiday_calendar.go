@@ -0,0 +1,302 @@
+package datasource
+
+import (
+	"database/sql"
+	"excel-report-email-scheduler/pkg/ereserror"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type HolidayCalendar struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Holidays []Holiday `json:"holidays"`
+}
+
+type Holiday struct {
+	// Date is the day of the holiday, formatted as yyyy-mm-dd
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+func HolidayCalendarFields() string {
+	return "\n{\n\tid string" +
+		"\n\tname string" +
+		"\n\tholidays []{ date string, name string }" +
+		"\n\tcontent string (an iCal file or a list of dates, instead of holidays)\n}"
+}
+
+func (datasource *MsupplyEresDatasource) GetHolidayCalendars() ([]HolidayCalendar, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	rows, err := sqlClient.Db.Query("SELECT id, name FROM HolidayCalendar ORDER BY name")
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get holiday calendar list")
+		return nil, err
+	}
+	defer rows.Close()
+
+	calendars := []HolidayCalendar{}
+	for rows.Next() {
+		var calendar HolidayCalendar
+		err = rows.Scan(&calendar.ID, &calendar.Name)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not scan holiday calendar rows")
+			return nil, err
+		}
+		calendars = append(calendars, calendar)
+	}
+
+	for i := range calendars {
+		calendars[i].Holidays, err = datasource.GetHolidays(calendars[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return calendars, nil
+}
+
+func (datasource *MsupplyEresDatasource) GetHolidayCalendar(id string) (*HolidayCalendar, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	var calendar HolidayCalendar
+	row := sqlClient.Db.QueryRow("SELECT id, name FROM HolidayCalendar WHERE id = ?", id)
+	err = row.Scan(&calendar.ID, &calendar.Name)
+	if err == sql.ErrNoRows {
+		err = ereserror.New(404, errors.Wrap(err, frame.Function), "Could not find holiday calendar with id: "+id)
+		return nil, err
+	}
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get holiday calendar")
+		return nil, err
+	}
+
+	calendar.Holidays, err = datasource.GetHolidays(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &calendar, nil
+}
+
+// GetHolidays returns the holidays of a calendar in date order.
+func (datasource *MsupplyEresDatasource) GetHolidays(calendarID string) ([]Holiday, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	rows, err := sqlClient.Db.Query("SELECT date, name FROM Holiday WHERE holidayCalendarID = ? ORDER BY date", calendarID)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get holidays")
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := []Holiday{}
+	for rows.Next() {
+		var holiday Holiday
+		err = rows.Scan(&holiday.Date, &holiday.Name)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not scan holiday rows")
+			return nil, err
+		}
+		holidays = append(holidays, holiday)
+	}
+
+	return holidays, nil
+}
+
+// CreateHolidayCalendar creates the calendar, or replaces the name and holidays of the
+// calendar with the same ID, which must exist.
+func (datasource *MsupplyEresDatasource) CreateHolidayCalendar(calendar HolidayCalendar) (*HolidayCalendar, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	tx, err := sqlClient.Db.Begin()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not save holiday calendar")
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if calendar.ID == "" {
+		calendar.ID = uuid.New().String()
+		_, err = tx.Exec("INSERT INTO HolidayCalendar (id, name) VALUES (?,?)", calendar.ID, calendar.Name)
+	} else {
+		var result sql.Result
+		var updated int64
+		result, err = tx.Exec("UPDATE HolidayCalendar SET name = ? WHERE id = ?", calendar.Name, calendar.ID)
+		if err == nil {
+			updated, err = result.RowsAffected()
+		}
+		if err == nil && updated == 0 {
+			err = ereserror.New(404, errors.Wrap(sql.ErrNoRows, frame.Function), "Could not find holiday calendar with id: "+calendar.ID)
+			return nil, err
+		}
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM Holiday WHERE holidayCalendarID = ?", calendar.ID)
+		}
+	}
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not save holiday calendar")
+		return nil, err
+	}
+
+	for _, holiday := range calendar.Holidays {
+		_, err = tx.Exec("INSERT OR IGNORE INTO Holiday (holidayCalendarID, date, name) VALUES (?,?,?)", calendar.ID, holiday.Date, holiday.Name)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not save holidays")
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not save holiday calendar")
+		return nil, err
+	}
+
+	scheduleIDs, err := datasource.scheduleIDsUsingCalendar(calendar.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = datasource.refreshNextReportTimes(scheduleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &calendar, nil
+}
+
+// DeleteHolidayCalendar deletes the calendar and detaches it from the schedules using it.
+func (datasource *MsupplyEresDatasource) DeleteHolidayCalendar(id string) error {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return err
+	}
+	defer sqlClient.Db.Close()
+
+	scheduleIDs, err := datasource.scheduleIDsUsingCalendar(id)
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		"UPDATE Schedule SET holidayCalendarID = '' WHERE holidayCalendarID = ?",
+		"DELETE FROM Holiday WHERE holidayCalendarID = ?",
+		"DELETE FROM HolidayCalendar WHERE id = ?",
+	} {
+		_, err = sqlClient.Db.Exec(query, id)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not delete holiday calendar")
+			return err
+		}
+	}
+
+	return datasource.refreshNextReportTimes(scheduleIDs)
+}
+
+func (datasource *MsupplyEresDatasource) scheduleIDsUsingCalendar(calendarID string) ([]string, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	rows, err := sqlClient.Db.Query("SELECT id FROM Schedule WHERE holidayCalendarID = ?", calendarID)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get the schedules using the holiday calendar")
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not scan schedule rows")
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// refreshNextReportTimes checks the next report time of the schedules again after their
+// holidays have changed. Schedules which are already due, including those waiting to retry
+// or catching up, are left alone so the runs they have pending are still sent.
+func (datasource *MsupplyEresDatasource) refreshNextReportTimes(scheduleIDs []string) error {
+	frame := trace()
+	now := time.Now()
+	for _, id := range scheduleIDs {
+		schedule, err := datasource.GetSchedule(id)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get schedule")
+			return err
+		}
+		if int64(schedule.NextReportTime) <= now.Unix() || schedule.RetryAfter > 0 {
+			continue
+		}
+
+		nextReportTime := schedule.NextReportTime
+		schedule.RefreshNextReportTime(now)
+		if schedule.NextReportTime == nextReportTime {
+			continue
+		}
+
+		err = datasource.SetNextReportTime(schedule.ID, schedule.NextReportTime)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update the next report time of schedule: "+schedule.Name)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AttachHolidays loads the holidays of the schedule's calendar, which its report times are
+// worked out with.
+func (datasource *MsupplyEresDatasource) AttachHolidays(schedule *Schedule) error {
+	if schedule.HolidayCalendarID == "" {
+		schedule.SetHolidays(nil)
+		return nil
+	}
+
+	holidays, err := datasource.GetHolidays(schedule.HolidayCalendarID)
+	if err != nil {
+		return err
+	}
+
+	schedule.SetHolidays(holidays)
+	return nil
+}
@@ -0,0 +1,130 @@
+package datasource
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const holidayDateLayout = "2006-01-02"
+
+// ParseHolidays reads holidays from an iCal file, or from a list with one date per line,
+// formatted as yyyy-mm-dd and optionally followed by a comma and the holiday's name. Blank
+// lines and lines starting with # are ignored.
+func ParseHolidays(content string) ([]Holiday, error) {
+	var holidays []Holiday
+	var err error
+
+	if strings.Contains(content, "BEGIN:VCALENDAR") {
+		holidays, err = parseICalHolidays(content)
+	} else {
+		holidays, err = parseHolidayList(content)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(holidays, func(i, j int) bool { return holidays[i].Date < holidays[j].Date })
+	return holidays, nil
+}
+
+func parseHolidayList(content string) ([]Holiday, error) {
+	holidays := []Holiday{}
+	for number, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		date, name := line, ""
+		if comma := strings.Index(line, ","); comma >= 0 {
+			date, name = strings.TrimSpace(line[:comma]), strings.TrimSpace(line[comma+1:])
+		}
+
+		if _, err := time.Parse(holidayDateLayout, date); err != nil {
+			return nil, fmt.Errorf("line %d: %s is not a date formatted as yyyy-mm-dd", number+1, date)
+		}
+		holidays = append(holidays, Holiday{Date: date, Name: name})
+	}
+
+	return holidays, nil
+}
+
+// parseICalHolidays reads the all-day events of an iCal file. Events with a start time, such
+// as meetings, are not holidays and are left out. An event lasting several days adds a holiday
+// for each of them. Recurring events are not expanded.
+func parseICalHolidays(content string) ([]Holiday, error) {
+	// lines starting with whitespace continue the line before them
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\n ", "")
+	content = strings.ReplaceAll(content, "\n\t", "")
+
+	holidays := []Holiday{}
+	var start, end, summary string
+	inEvent, timed := false, false
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		// property parameters, such as ;VALUE=DATE, come before the colon
+		name := strings.ToUpper(strings.SplitN(line[:colon], ";", 2)[0])
+		value := line[colon+1:]
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent = true
+			start, end, summary = "", "", ""
+			timed = false
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if timed {
+				continue
+			}
+			eventHolidays, err := icalEventHolidays(start, end, summary)
+			if err != nil {
+				return nil, err
+			}
+			holidays = append(holidays, eventHolidays...)
+		case inEvent && name == "DTSTART":
+			start = value
+			// all-day events start on a date, such as 20240101, and others on a date and time
+			timed = strings.Contains(value, "T")
+		case inEvent && name == "DTEND":
+			end = value
+		case inEvent && name == "SUMMARY":
+			summary = strings.ReplaceAll(value, "\\,", ",")
+		}
+	}
+
+	return holidays, nil
+}
+
+func icalEventHolidays(start string, end string, summary string) ([]Holiday, error) {
+	if len(start) < 8 {
+		return nil, fmt.Errorf("event %q has no valid start date", summary)
+	}
+
+	first, err := time.Parse("20060102", start[:8])
+	if err != nil {
+		return nil, fmt.Errorf("event %q has no valid start date", summary)
+	}
+
+	// the end date of an all-day event is the day after it finishes
+	last := first
+	if len(end) >= 8 {
+		if endDate, err := time.Parse("20060102", end[:8]); err == nil && endDate.After(first) {
+			last = endDate.AddDate(0, 0, -1)
+		}
+	}
+
+	holidays := []Holiday{}
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		holidays = append(holidays, Holiday{Date: day.Format(holidayDateLayout), Name: summary})
+	}
+
+	return holidays, nil
+}
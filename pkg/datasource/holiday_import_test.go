@@ -0,0 +1,70 @@
+package datasource
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHolidays(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Holiday
+		wantErr bool
+	}{
+		{
+			name:    "list with names, comments and blank lines",
+			content: "# holidays\n2024-12-26, Boxing Day\n\n2024-12-25,Christmas Day\n2025-01-01\n",
+			want: []Holiday{
+				{Date: "2024-12-25", Name: "Christmas Day"},
+				{Date: "2024-12-26", Name: "Boxing Day"},
+				{Date: "2025-01-01"},
+			},
+		},
+		{
+			name:    "list with a date which is not yyyy-mm-dd",
+			content: "25/12/2024, Christmas Day\n",
+			wantErr: true,
+		},
+		{
+			name: "iCal all-day events, one lasting two days",
+			content: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20241225\r\nDTEND;VALUE=DATE:20241227\r\nSUMMARY:Christmas\\, Boxing Day\r\nEND:VEVENT\r\n" +
+				"BEGIN:VEVENT\r\nDTSTART:20250101\r\nSUMMARY:New Year\r\n 's Day\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			want: []Holiday{
+				{Date: "2024-12-25", Name: "Christmas, Boxing Day"},
+				{Date: "2024-12-26", Name: "Christmas, Boxing Day"},
+				{Date: "2025-01-01", Name: "New Year's Day"},
+			},
+		},
+		{
+			name: "iCal events with a start time are left out",
+			content: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;TZID=Pacific/Auckland:20241220T090000\nSUMMARY:Team meeting\nEND:VEVENT\n" +
+				"BEGIN:VEVENT\nDTSTART:20241223T000000Z\nSUMMARY:Stocktake\nEND:VEVENT\n" +
+				"BEGIN:VEVENT\nDTSTART;VALUE=DATE:20241225\nSUMMARY:Christmas Day\nEND:VEVENT\nEND:VCALENDAR\n",
+			want: []Holiday{{Date: "2024-12-25", Name: "Christmas Day"}},
+		},
+		{
+			name:    "iCal all-day event without a start date",
+			content: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Someday\nEND:VEVENT\nEND:VCALENDAR\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseHolidays(test.content)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("ParseHolidays returned %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseHolidays returned an error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseHolidays = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -9,20 +9,55 @@ import (
 	"github.com/pkg/errors"
 )
 
-func NewSchedule(ID string, interval int, nextReportTime int, name string, description string, lookback string, reportGroupID string, time string, day int) Schedule {
+const scheduleColumns = "id, interval, nextReportTime, name, description, lookback, reportGroupID, time, day, cronExpression, timeZone, retryMaxAttempts, retryBackoffSeconds, retryAfter, catchUpPolicy, enabled, startDate, endDate, holidayCalendarID, businessDayRule, fiscalYearStartMonth, format, inlineRows, templateID, summarySheet, layout"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSchedule(row rowScanner) (Schedule, error) {
+	var ID, Name, Description, ReportGroupID, Time, Lookback, CronExpression, TimeZone, CatchUpPolicy, HolidayCalendarID, BusinessDayRule, Format, TemplateID, Layout string
+	var Day, Interval, NextReportTime, RetryMaxAttempts, RetryBackoffSeconds, RetryAfter, StartDate, EndDate, FiscalYearStartMonth, InlineRows int
+	var Enabled, SummarySheet bool
+
+	err := row.Scan(&ID, &Interval, &NextReportTime, &Name, &Description, &Lookback, &ReportGroupID, &Time, &Day, &CronExpression, &TimeZone, &RetryMaxAttempts, &RetryBackoffSeconds, &RetryAfter, &CatchUpPolicy, &Enabled, &StartDate, &EndDate, &HolidayCalendarID, &BusinessDayRule, &FiscalYearStartMonth, &Format, &InlineRows, &TemplateID, &SummarySheet, &Layout)
+	if err != nil {
+		return Schedule{}, err
+	}
+
 	schedule := Schedule{
 		ID:             ID,
-		Interval:       interval,
-		NextReportTime: nextReportTime,
-		Name:           name,
-		Description:    description,
-		Lookback:       lookback,
-		ReportGroupID:  reportGroupID,
-		Time:           time,
-		Day:            day,
+		Interval:       Interval,
+		NextReportTime: NextReportTime,
+		Name:           Name,
+		Description:    Description,
+		Lookback:       Lookback,
+		ReportGroupID:  ReportGroupID,
+		Time:           Time,
+		Day:            Day,
+		CronExpression: CronExpression,
+		TimeZone:       TimeZone,
 		PanelDetails:   []ReportContent{},
+		DependsOn:      []string{},
+
+		RetryMaxAttempts:    RetryMaxAttempts,
+		RetryBackoffSeconds: RetryBackoffSeconds,
+		RetryAfter:          RetryAfter,
+		CatchUpPolicy:       CatchUpPolicy,
+		Enabled:             Enabled,
+		StartDate:           StartDate,
+		EndDate:             EndDate,
+		HolidayCalendarID:   HolidayCalendarID,
+		BusinessDayRule:     BusinessDayRule,
+
+		FiscalYearStartMonth: FiscalYearStartMonth,
+		Format:               Format,
+		InlineRows:           InlineRows,
+		TemplateID:           TemplateID,
+		SummarySheet:         SummarySheet,
+		Layout:               Layout,
 	}
-	return schedule
+	return schedule, nil
 }
 
 func (datasource *MsupplyEresDatasource) GetSchedules() ([]Schedule, error) {
@@ -36,7 +71,7 @@ func (datasource *MsupplyEresDatasource) GetSchedules() ([]Schedule, error) {
 
 	var schedules []Schedule
 
-	rows, err := sqlClient.Db.Query("SELECT * FROM Schedule")
+	rows, err := sqlClient.Db.Query("SELECT " + scheduleColumns + " FROM Schedule")
 	if err != nil {
 		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get schedule list")
 		return nil, err
@@ -44,33 +79,30 @@ func (datasource *MsupplyEresDatasource) GetSchedules() ([]Schedule, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var ID, Name, Description, ReportGroupID, Time, Lookback string
-		var Day, Interval, NextReportTime int
-
-		err = rows.Scan(&ID, &Interval, &NextReportTime, &Name, &Description, &Lookback, &ReportGroupID, &Time, &Day)
+		schedule, err := scanSchedule(rows)
 		if err != nil {
 			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not scan schedule rows")
 			return nil, err
 		}
 
-		reportContent, err := datasource.GetReportContent(ID)
+		err = datasource.AttachHolidays(&schedule)
 		if err != nil {
-			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get panel details")
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get holidays")
 			return nil, err
 		}
 
-		schedule := Schedule{
-			ID:             ID,
-			Interval:       Interval,
-			NextReportTime: NextReportTime,
-			Name:           Name,
-			Description:    Description,
-			Lookback:       Lookback,
-			ReportGroupID:  ReportGroupID,
-			Time:           Time,
-			Day:            Day,
-			PanelDetails:   reportContent,
+		schedule.DependsOn, err = datasource.GetScheduleDependencies(schedule.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		reportContent, err := datasource.GetReportContent(schedule.ID)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get panel details")
+			return nil, err
 		}
+
+		schedule.PanelDetails = reportContent
 		schedules = append(schedules, schedule)
 	}
 
@@ -88,7 +120,7 @@ func (datasource *MsupplyEresDatasource) GetSchedule(id string) (*Schedule, erro
 
 	var schedules []Schedule
 
-	rows, err := db.Query("SELECT * FROM Schedule where id=?", id)
+	rows, err := db.Query("SELECT "+scheduleColumns+" FROM Schedule where id=?", id)
 	defer rows.Close()
 	if err != nil {
 		log.DefaultLogger.Error("GetSchedules: db.Query(): ", err.Error())
@@ -96,33 +128,31 @@ func (datasource *MsupplyEresDatasource) GetSchedule(id string) (*Schedule, erro
 	}
 
 	for rows.Next() {
-		var ID, Name, Description, ReportGroupID, Time, Lookback string
-		var Day, Interval, NextReportTime int
-
-		err = rows.Scan(&ID, &Interval, &NextReportTime, &Name, &Description, &Lookback, &ReportGroupID, &Time, &Day)
+		schedule, err := scanSchedule(rows)
 		if err != nil {
 			log.DefaultLogger.Error("GetSchedules: rows.Scan(): ", err.Error())
 			return nil, err
 		}
 
-		reportContent, err := datasource.GetReportContent(ID)
+		err = datasource.AttachHolidays(&schedule)
 		if err != nil {
-			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get panel details")
+			log.DefaultLogger.Error("GetSchedule: AttachHolidays(): ", err.Error())
+			return nil, err
+		}
+
+		schedule.DependsOn, err = datasource.GetScheduleDependencies(schedule.ID)
+		if err != nil {
+			log.DefaultLogger.Error("GetSchedule: GetScheduleDependencies(): ", err.Error())
 			return nil, err
 		}
 
-		schedule := Schedule{
-			ID:             id,
-			Interval:       Interval,
-			NextReportTime: NextReportTime,
-			Name:           Name,
-			Description:    Description,
-			Lookback:       Lookback,
-			ReportGroupID:  ReportGroupID,
-			Time:           Time,
-			Day:            Day,
-			PanelDetails:   reportContent,
+		reportContent, err := datasource.GetReportContent(schedule.ID)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get panel details")
+			return nil, err
 		}
+
+		schedule.PanelDetails = reportContent
 		schedules = append(schedules, schedule)
 	}
 
@@ -134,6 +164,7 @@ func (datasource *MsupplyEresDatasource) GetSchedule(id string) (*Schedule, erro
 
 }
 
+// UpdateSchedule saves the schedule, including the next report time it has been given.
 func (datasource *MsupplyEresDatasource) UpdateSchedule(id string, schedule Schedule) (*Schedule, error) {
 	db, err := sql.Open("sqlite", datasource.DataPath)
 	defer db.Close()
@@ -142,23 +173,113 @@ func (datasource *MsupplyEresDatasource) UpdateSchedule(id string, schedule Sche
 		return nil, err
 	}
 
-	stmt, err := db.Prepare("UPDATE Schedule SET nextReportTime = ?, interval = ?, name = ?, description = ?, lookback = ?, reportGroupID = ?, time = ?, day = ? where id = ?")
+	stmt, err := db.Prepare("UPDATE Schedule SET nextReportTime = ?, interval = ?, name = ?, description = ?, lookback = ?, reportGroupID = ?, time = ?, day = ?, cronExpression = ?, timeZone = ?, retryMaxAttempts = ?, retryBackoffSeconds = ?, retryAfter = 0, catchUpPolicy = ?, startDate = ?, endDate = ?, holidayCalendarID = ?, businessDayRule = ?, fiscalYearStartMonth = ?, format = ?, inlineRows = ?, templateID = ?, summarySheet = ?, layout = ? where id = ?")
 	if err != nil {
 		log.DefaultLogger.Error("UpdateSchedule: db.Prepare()", err.Error())
 		return nil, err
 	}
 
-	schedule.UpdateNextReportTime()
-	_, err = stmt.Exec(schedule.NextReportTime, schedule.Interval, schedule.Name, schedule.Description, schedule.Lookback, schedule.ReportGroupID, schedule.Time, schedule.Day, id)
+	_, err = stmt.Exec(schedule.NextReportTime, schedule.Interval, schedule.Name, schedule.Description, schedule.Lookback, schedule.ReportGroupID, schedule.Time, schedule.Day, schedule.CronExpression, schedule.TimeZone, schedule.RetryMaxAttempts, schedule.RetryBackoffSeconds, schedule.CatchUpPolicy, schedule.StartDate, schedule.EndDate, schedule.HolidayCalendarID, schedule.BusinessDayRule, schedule.FiscalYearStartMonth, schedule.Format, schedule.InlineRows, schedule.TemplateID, schedule.SummarySheet, schedule.Layout, id)
 	defer stmt.Close()
 	if err != nil {
 		log.DefaultLogger.Error("UpdateSchedule: stmt.Exec()", err.Error())
 		return nil, err
 	}
 
+	schedule.RetryAfter = 0
 	return &schedule, nil
 }
 
+// AdvanceSchedule moves a schedule which has run for reportTime on to nextReportTime and clears
+// any retry it was waiting for. Only those columns are written, and only while the schedule is
+// still waiting for reportTime, so changes made to it in the meantime are kept.
+func (datasource *MsupplyEresDatasource) AdvanceSchedule(id string, reportTime int, nextReportTime int) error {
+	db, err := sql.Open("sqlite", datasource.DataPath)
+	defer db.Close()
+	if err != nil {
+		log.DefaultLogger.Error("AdvanceSchedule: sql.Open()", err.Error())
+		return err
+	}
+
+	_, err = db.Exec("UPDATE Schedule SET nextReportTime = ?, retryAfter = 0 WHERE id = ? AND nextReportTime = ?", nextReportTime, id, reportTime)
+	if err != nil {
+		log.DefaultLogger.Error("AdvanceSchedule: db.Exec()", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// SetNextReportTime saves only the schedule's next report time, leaving the rest of it, and
+// any retry it is waiting for, as they are.
+func (datasource *MsupplyEresDatasource) SetNextReportTime(id string, nextReportTime int) error {
+	db, err := sql.Open("sqlite", datasource.DataPath)
+	defer db.Close()
+	if err != nil {
+		log.DefaultLogger.Error("SetNextReportTime: sql.Open()", err.Error())
+		return err
+	}
+
+	_, err = db.Exec("UPDATE Schedule SET nextReportTime = ? WHERE id = ?", nextReportTime, id)
+	if err != nil {
+		log.DefaultLogger.Error("SetNextReportTime: db.Exec()", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// RetryScheduleAfter holds back an overdue schedule until retryAfter, so a failed run is
+// retried without moving the schedule on to its next report time.
+func (datasource *MsupplyEresDatasource) RetryScheduleAfter(id string, retryAfter int) error {
+	db, err := sql.Open("sqlite", datasource.DataPath)
+	defer db.Close()
+	if err != nil {
+		log.DefaultLogger.Error("RetryScheduleAfter: sql.Open()", err.Error())
+		return err
+	}
+
+	_, err = db.Exec("UPDATE Schedule SET retryAfter = ? WHERE id = ?", retryAfter, id)
+	if err != nil {
+		log.DefaultLogger.Error("RetryScheduleAfter: db.Exec()", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// SetScheduleEnabled pauses or resumes a schedule. A resumed schedule moves on to its next
+// report time from now, so the report times missed while it was paused are not sent.
+func (datasource *MsupplyEresDatasource) SetScheduleEnabled(id string, enabled bool) (*Schedule, error) {
+	frame := trace()
+	schedule, err := datasource.GetSchedule(id)
+	if err != nil {
+		err = ereserror.New(404, errors.Wrap(err, frame.Function), "Could not find schedule with id: "+id)
+		return nil, err
+	}
+
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	if enabled {
+		schedule.UpdateNextReportTime()
+		schedule.RetryAfter = 0
+	}
+	schedule.Enabled = enabled
+
+	_, err = sqlClient.Db.Exec("UPDATE Schedule SET enabled = ?, nextReportTime = ?, retryAfter = ? WHERE id = ?", schedule.Enabled, schedule.NextReportTime, schedule.RetryAfter, id)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update schedule record")
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
 func (datasource *MsupplyEresDatasource) DeleteSchedule(id string) error {
 	db, err := sql.Open("sqlite", datasource.DataPath)
 	defer db.Close()
@@ -192,6 +313,30 @@ func (datasource *MsupplyEresDatasource) DeleteSchedule(id string) error {
 		return err
 	}
 
+	stmt, err = db.Prepare("DELETE FROM ScheduleRun WHERE scheduleID = ?")
+	if err != nil {
+		log.DefaultLogger.Error("DeleteSchedule: db.Prepare()3", err.Error())
+		return err
+	}
+
+	_, err = stmt.Exec(id)
+	if err != nil {
+		log.DefaultLogger.Error("DeleteSchedule: stmt.Exec()3", err.Error())
+		return err
+	}
+
+	stmt, err = db.Prepare("DELETE FROM ScheduleDependency WHERE scheduleID = ? OR dependsOnID = ?")
+	if err != nil {
+		log.DefaultLogger.Error("DeleteSchedule: db.Prepare()4", err.Error())
+		return err
+	}
+
+	_, err = stmt.Exec(id, id)
+	if err != nil {
+		log.DefaultLogger.Error("DeleteSchedule: stmt.Exec()4", err.Error())
+		return err
+	}
+
 	return nil
 }
 
@@ -203,7 +348,7 @@ func (datasource *MsupplyEresDatasource) OverdueSchedules() ([]Schedule, error)
 		return nil, err
 	}
 
-	rows, err := db.Query("SELECT * FROM Schedule WHERE strftime(\"%s\", \"now\") > nextReportTime")
+	rows, err := db.Query("SELECT " + scheduleColumns + " FROM Schedule WHERE strftime(\"%s\", \"now\") > nextReportTime AND strftime(\"%s\", \"now\") >= retryAfter AND enabled = 1 AND strftime(\"%s\", \"now\") >= startDate AND (endDate = 0 OR nextReportTime <= endDate)")
 	if err != nil {
 		log.DefaultLogger.Error("OverdueSchedules: db.Query", err.Error())
 		return nil, err
@@ -211,14 +356,24 @@ func (datasource *MsupplyEresDatasource) OverdueSchedules() ([]Schedule, error)
 
 	var schedules []Schedule
 	for rows.Next() {
-		var ID, Name, Description, ReportGroupID, Time, Lookback string
-		var Day, Interval, NextReportTime int
-		err = rows.Scan(&ID, &Interval, &NextReportTime, &Name, &Description, &Lookback, &ReportGroupID, &Time, &Day)
+		schedule, err := scanSchedule(rows)
 		if err != nil {
 			log.DefaultLogger.Error("OverdueSchedules: sql.Open", err.Error())
 			return nil, err
 		}
-		schedules = append(schedules, NewSchedule(ID, Interval, NextReportTime, Name, Description, Lookback, ReportGroupID, Time, Day))
+
+		err = datasource.AttachHolidays(&schedule)
+		if err != nil {
+			log.DefaultLogger.Error("OverdueSchedules: AttachHolidays", err.Error())
+			return nil, err
+		}
+
+		schedule.DependsOn, err = datasource.GetScheduleDependencies(schedule.ID)
+		if err != nil {
+			log.DefaultLogger.Error("OverdueSchedules: GetScheduleDependencies", err.Error())
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
 	}
 
 	return schedules, nil
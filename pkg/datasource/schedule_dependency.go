@@ -0,0 +1,136 @@
+package datasource
+
+import (
+	"excel-report-email-scheduler/pkg/ereserror"
+
+	"github.com/pkg/errors"
+)
+
+// GetScheduleDependencies returns the IDs of the upstream schedules the schedule waits for.
+func (datasource *MsupplyEresDatasource) GetScheduleDependencies(scheduleID string) ([]string, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	rows, err := sqlClient.Db.Query("SELECT dependsOnID FROM ScheduleDependency WHERE scheduleID = ? ORDER BY dependsOnID", scheduleID)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get schedule dependencies")
+		return nil, err
+	}
+	defer rows.Close()
+
+	dependsOn := []string{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not scan schedule dependency rows")
+			return nil, err
+		}
+		dependsOn = append(dependsOn, id)
+	}
+
+	return dependsOn, nil
+}
+
+// GetAllScheduleDependencies returns the upstream schedule IDs of every schedule with any, by schedule ID.
+func (datasource *MsupplyEresDatasource) GetAllScheduleDependencies() (map[string][]string, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	rows, err := sqlClient.Db.Query("SELECT scheduleID, dependsOnID FROM ScheduleDependency")
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get schedule dependencies")
+		return nil, err
+	}
+	defer rows.Close()
+
+	dependencies := make(map[string][]string)
+	for rows.Next() {
+		var scheduleID, dependsOnID string
+		if err = rows.Scan(&scheduleID, &dependsOnID); err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not scan schedule dependency rows")
+			return nil, err
+		}
+		dependencies[scheduleID] = append(dependencies[scheduleID], dependsOnID)
+	}
+
+	return dependencies, nil
+}
+
+// SetScheduleDependencies replaces the upstream schedules the schedule waits for.
+func (datasource *MsupplyEresDatasource) SetScheduleDependencies(scheduleID string, dependsOn []string) error {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return err
+	}
+	defer sqlClient.Db.Close()
+
+	_, err = sqlClient.Db.Exec("DELETE FROM ScheduleDependency WHERE scheduleID = ?", scheduleID)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update schedule dependencies")
+		return err
+	}
+
+	for _, dependsOnID := range dependsOn {
+		_, err = sqlClient.Db.Exec("INSERT OR IGNORE INTO ScheduleDependency (scheduleID, dependsOnID) VALUES (?,?)", scheduleID, dependsOnID)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update schedule dependencies")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LastDeliveredReportTime returns the latest report time before the given one which a scheduled
+// run of the schedule succeeded, or partly succeeded, for. It is 0 if there is none.
+func (datasource *MsupplyEresDatasource) LastDeliveredReportTime(scheduleID string, before int) (int, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return 0, err
+	}
+	defer sqlClient.Db.Close()
+
+	var reportTime int
+	row := sqlClient.Db.QueryRow("SELECT IFNULL(MAX(scheduledTime), 0) FROM ScheduleRun WHERE scheduleID = ? AND scheduledTime < ? AND status IN (?, ?)", scheduleID, before, ScheduleRunStatusSucceeded, ScheduleRunStatusPartial)
+	if err = row.Scan(&reportTime); err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get schedule runs")
+		return 0, err
+	}
+
+	return reportTime, nil
+}
+
+// SucceededBetween reports whether a scheduled run of the schedule succeeded for a report time
+// after from and up to and including to.
+func (datasource *MsupplyEresDatasource) SucceededBetween(scheduleID string, from int, to int) (bool, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return false, err
+	}
+	defer sqlClient.Db.Close()
+
+	var count int
+	row := sqlClient.Db.QueryRow("SELECT COUNT(*) FROM ScheduleRun WHERE scheduleID = ? AND scheduledTime > ? AND scheduledTime <= ? AND status = ?", scheduleID, from, to, ScheduleRunStatusSucceeded)
+	if err = row.Scan(&count); err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get schedule runs")
+		return false, err
+	}
+
+	return count > 0, nil
+}
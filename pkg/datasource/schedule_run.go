@@ -0,0 +1,282 @@
+package datasource
+
+import (
+	"database/sql"
+	"encoding/json"
+	"excel-report-email-scheduler/pkg/ereserror"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	ScheduleRunStatusQueued    = "queued"
+	ScheduleRunStatusRunning   = "running"
+	ScheduleRunStatusSucceeded = "succeeded"
+	ScheduleRunStatusPartial   = "partial"
+	ScheduleRunStatusFailed    = "failed"
+	// ScheduleRunStatusBlocked records a report time held back waiting for upstream schedules
+	ScheduleRunStatusBlocked = "blocked"
+)
+
+const scheduleRunColumns = "id, scheduleID, startTime, endTime, status, panelRowCounts, recipientsAttempted, recipientsSucceeded, error, scheduledTime, attempt, deliveredTo"
+
+type PanelRowCount struct {
+	PanelID int    `json:"panelID"`
+	Title   string `json:"title"`
+	Rows    int    `json:"rows"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ScheduleRun struct {
+	ID                  string          `json:"id"`
+	ScheduleID          string          `json:"scheduleID"`
+	StartTime           int             `json:"startTime"`
+	EndTime             int             `json:"endTime"`
+	Status              string          `json:"status"`
+	PanelRowCounts      []PanelRowCount `json:"panelRowCounts"`
+	RecipientsAttempted int             `json:"recipientsAttempted"`
+	RecipientsSucceeded int             `json:"recipientsSucceeded"`
+	Error               string          `json:"error"`
+	// ScheduledTime is the report time a scheduled run is for, and 0 for runs requested by hand
+	ScheduledTime int `json:"scheduledTime"`
+	// Attempt counts the runs made for the same ScheduledTime, starting at 1
+	Attempt int `json:"attempt"`
+	// DeliveredTo lists the addresses the report was sent to successfully
+	DeliveredTo []string `json:"deliveredTo"`
+}
+
+func scanScheduleRun(row rowScanner) (ScheduleRun, error) {
+	var run ScheduleRun
+	var panelRowCounts, deliveredTo string
+
+	err := row.Scan(&run.ID, &run.ScheduleID, &run.StartTime, &run.EndTime, &run.Status, &panelRowCounts, &run.RecipientsAttempted, &run.RecipientsSucceeded, &run.Error, &run.ScheduledTime, &run.Attempt, &deliveredTo)
+	if err != nil {
+		return ScheduleRun{}, err
+	}
+
+	run.PanelRowCounts = []PanelRowCount{}
+	if panelRowCounts != "" {
+		err = json.Unmarshal([]byte(panelRowCounts), &run.PanelRowCounts)
+		if err != nil {
+			return ScheduleRun{}, err
+		}
+	}
+
+	run.DeliveredTo = []string{}
+	if deliveredTo != "" {
+		err = json.Unmarshal([]byte(deliveredTo), &run.DeliveredTo)
+		if err != nil {
+			return ScheduleRun{}, err
+		}
+	}
+
+	return run, nil
+}
+
+// CreateScheduleRun records a new run of a schedule. The run's ID and start time are filled in.
+func (datasource *MsupplyEresDatasource) CreateScheduleRun(run ScheduleRun) (*ScheduleRun, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	run.ID = uuid.New().String()
+	run.StartTime = int(time.Now().Unix())
+	run.PanelRowCounts = []PanelRowCount{}
+	run.DeliveredTo = []string{}
+	if run.Attempt <= 0 {
+		run.Attempt = 1
+	}
+
+	stmt, err := sqlClient.Db.Prepare("INSERT INTO ScheduleRun (" + scheduleRunColumns + ") VALUES (?,?,?,?,?,?,?,?,?,?,?,?)")
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not create schedule run")
+		return nil, err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(run.ID, run.ScheduleID, run.StartTime, run.EndTime, run.Status, "[]", run.RecipientsAttempted, run.RecipientsSucceeded, run.Error, run.ScheduledTime, run.Attempt, "[]")
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not create schedule run")
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+func (datasource *MsupplyEresDatasource) UpdateScheduleRun(run ScheduleRun) error {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return err
+	}
+	defer sqlClient.Db.Close()
+
+	panelRowCounts, err := json.Marshal(run.PanelRowCounts)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update schedule run")
+		return err
+	}
+
+	deliveredTo, err := json.Marshal(run.DeliveredTo)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update schedule run")
+		return err
+	}
+
+	stmt, err := sqlClient.Db.Prepare("UPDATE ScheduleRun SET startTime = ?, endTime = ?, status = ?, panelRowCounts = ?, recipientsAttempted = ?, recipientsSucceeded = ?, error = ?, deliveredTo = ? WHERE id = ?")
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update schedule run")
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(run.StartTime, run.EndTime, run.Status, string(panelRowCounts), run.RecipientsAttempted, run.RecipientsSucceeded, run.Error, string(deliveredTo), run.ID)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update schedule run")
+		return err
+	}
+
+	return nil
+}
+
+func (datasource *MsupplyEresDatasource) GetScheduleRun(id string) (*ScheduleRun, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	row := sqlClient.Db.QueryRow("SELECT "+scheduleRunColumns+" FROM ScheduleRun WHERE id = ?", id)
+	run, err := scanScheduleRun(row)
+	if err == sql.ErrNoRows {
+		err = ereserror.New(404, errors.Wrap(err, frame.Function), "Could not find schedule run with id: "+id)
+		return nil, err
+	}
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get schedule run")
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// GetScheduleRuns returns the run history of a schedule, most recent first.
+func (datasource *MsupplyEresDatasource) GetScheduleRuns(scheduleID string) ([]ScheduleRun, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	rows, err := sqlClient.Db.Query("SELECT "+scheduleRunColumns+" FROM ScheduleRun WHERE scheduleID = ? ORDER BY startTime DESC", scheduleID)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get schedule runs")
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []ScheduleRun{}
+	for rows.Next() {
+		run, err := scanScheduleRun(rows)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not scan schedule run rows")
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+// ScheduledRuns returns the runs already made for one report time of a schedule, oldest first.
+func (datasource *MsupplyEresDatasource) ScheduledRuns(scheduleID string, scheduledTime int) ([]ScheduleRun, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	rows, err := sqlClient.Db.Query("SELECT "+scheduleRunColumns+" FROM ScheduleRun WHERE scheduleID = ? AND scheduledTime = ? ORDER BY attempt", scheduleID, scheduledTime)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get schedule runs")
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []ScheduleRun{}
+	for rows.Next() {
+		run, err := scanScheduleRun(rows)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not scan schedule run rows")
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+// NeedsRetry reports whether another attempt could deliver what this run did not: the run
+// failed, or the report did not reach every recipient.
+func (run *ScheduleRun) NeedsRetry() bool {
+	return run.Status == ScheduleRunStatusFailed || run.RecipientsSucceeded < run.RecipientsAttempted
+}
+
+// Start marks a queued run as running from now.
+func (run *ScheduleRun) Start() {
+	run.StartTime = int(time.Now().Unix())
+	run.Status = ScheduleRunStatusRunning
+}
+
+// Finish records the outcome of the run. Panels which could not be loaded, or failing to
+// deliver to some of the recipients, mark the run as partial rather than failed.
+func (run *ScheduleRun) Finish(err error) {
+	run.EndTime = int(time.Now().Unix())
+
+	problems := []string{}
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	failedPanels := 0
+	for _, panel := range run.PanelRowCounts {
+		if panel.Error != "" {
+			failedPanels++
+		}
+	}
+	if failedPanels > 0 {
+		problems = append(problems, fmt.Sprintf("Could not load %d of %d panels", failedPanels, len(run.PanelRowCounts)))
+	}
+
+	if run.RecipientsSucceeded < run.RecipientsAttempted {
+		problems = append(problems, fmt.Sprintf("Could not send the report to %d of %d recipients", run.RecipientsAttempted-run.RecipientsSucceeded, run.RecipientsAttempted))
+	}
+
+	run.Error = strings.Join(problems, "; ")
+
+	switch {
+	case err != nil:
+		run.Status = ScheduleRunStatusFailed
+	case run.RecipientsAttempted > 0 && run.RecipientsSucceeded == 0:
+		run.Status = ScheduleRunStatusFailed
+	case len(problems) > 0:
+		run.Status = ScheduleRunStatusPartial
+	default:
+		run.Status = ScheduleRunStatusSucceeded
+	}
+}
@@ -0,0 +1,26 @@
+package datasource
+
+import (
+	"testing"
+)
+
+func TestNeedsRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		run  ScheduleRun
+		want bool
+	}{
+		{name: "failed", run: ScheduleRun{Status: ScheduleRunStatusFailed}, want: true},
+		{name: "partial with recipients left to deliver to", run: ScheduleRun{Status: ScheduleRunStatusPartial, RecipientsAttempted: 3, RecipientsSucceeded: 2}, want: true},
+		{name: "partial with every recipient delivered to", run: ScheduleRun{Status: ScheduleRunStatusPartial, RecipientsAttempted: 3, RecipientsSucceeded: 3}},
+		{name: "succeeded", run: ScheduleRun{Status: ScheduleRunStatusSucceeded, RecipientsAttempted: 3, RecipientsSucceeded: 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.run.NeedsRetry(); got != test.want {
+				t.Errorf("NeedsRetry = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/pkg/errors"
+	"github.com/robfig/cron"
 )
 
 type Schedule struct {
@@ -20,9 +21,82 @@ type Schedule struct {
 	ReportGroupID  string          `json:"reportGroupID"`
 	Time           string          `json:"time"`
 	Day            int             `json:"day"`
+	CronExpression string          `json:"cronExpression"`
+	TimeZone       string          `json:"timeZone"`
 	PanelDetails   []ReportContent `json:"panelDetails"`
+
+	// RetryMaxAttempts is how many times a scheduled run is attempted before it is given up on
+	RetryMaxAttempts int `json:"retryMaxAttempts"`
+	// RetryBackoffSeconds is the wait before the first retry, doubling for each retry after it
+	RetryBackoffSeconds int `json:"retryBackoffSeconds"`
+	// RetryAfter holds back a schedule whose last run failed until this unix time
+	RetryAfter int `json:"retryAfter"`
+	// CatchUpPolicy decides what happens to the report times missed while the plugin was not running
+	CatchUpPolicy string `json:"catchUpPolicy"`
+	// Enabled is cleared while the schedule is paused. It is only changed by pausing and resuming.
+	Enabled bool `json:"enabled"`
+	// StartDate and EndDate are unix times limiting when the schedule sends reports, 0 for no limit
+	StartDate int `json:"startDate"`
+	EndDate   int `json:"endDate"`
+	// HolidayCalendarID is the calendar of holidays which are not business days for the schedule
+	HolidayCalendarID string `json:"holidayCalendarID"`
+	// BusinessDayRule moves report times which do not fall on a business day
+	BusinessDayRule string `json:"businessDayRule"`
+	// FiscalYearStartMonth is the month, 1 to 12, the fiscal years of calendar-aligned lookbacks
+	// start in. 0 means January.
+	FiscalYearStartMonth int `json:"fiscalYearStartMonth"`
+	// Format is the file format the report is sent in, xlsx if not set
+	Format string `json:"format"`
+	// InlineRows is how many rows of each panel are shown in the body of the email, 0 for none
+	InlineRows int `json:"inlineRows"`
+	// TemplateID is the uploaded Excel template the report is built from, the default template if not set
+	TemplateID string `json:"templateID"`
+	// SummarySheet adds a first sheet to Excel reports listing and linking to each panel's sheet
+	SummarySheet bool `json:"summarySheet"`
+	// Layout is how the panels of Excel reports are laid out, a sheet for each if not set
+	Layout string `json:"layout"`
+	// DependsOn lists the upstream schedules which must succeed before this schedule runs
+	DependsOn []string `json:"dependsOn"`
+
+	// holidays holds the dates of the holidays in the schedule's calendar
+	holidays map[string]bool
 }
 
+const DEFAULT_RETRY_MAX_ATTEMPTS = 3
+const DEFAULT_RETRY_BACKOFF_SECONDS = 300
+const MAX_RETRY_BACKOFF_SECONDS = 24 * 60 * 60
+
+const (
+	// CatchUpPolicyOnce runs a schedule once for all of its missed report times; it is the default
+	CatchUpPolicyOnce = "once"
+	// CatchUpPolicyAll runs a schedule for each missed report time, with lookbacks ending at that time
+	CatchUpPolicyAll = "all"
+	// CatchUpPolicySkip skips the missed report times and waits for the next one
+	CatchUpPolicySkip = "skip"
+)
+
+const (
+	// ReportFormatXlsx writes each panel to a sheet of an Excel workbook built from the template; it is the default
+	ReportFormatXlsx = "xlsx"
+	// ReportFormatCsv writes each panel to a CSV file, zipped together when there is more than one
+	ReportFormatCsv = "csv"
+	// ReportFormatPdf writes each panel to a table in a PDF document
+	ReportFormatPdf = "pdf"
+)
+
+const (
+	// LayoutSheets writes each panel of an Excel report to a sheet of its own; it is the default
+	LayoutSheets = "sheets"
+	// LayoutStacked writes the panels of an Excel report one below another on a single sheet
+	LayoutStacked = "stacked"
+)
+
+// MAX_INLINE_ROWS limits how many rows of each panel can be shown in the body of the email.
+const MAX_INLINE_ROWS = 100
+
+// MAX_CATCH_UP_RUNS limits how many missed report times a schedule catching up on all of them runs for.
+const MAX_CATCH_UP_RUNS = 100
+
 type ReportContent struct {
 	ID          string `json:"id"`
 	ScheduleID  string `json:"scheduleID"`
@@ -41,8 +115,14 @@ func (datasource *MsupplyEresDatasource) CreateScheduleWithDetails(scheduleWithD
 	}
 	defer sqlClient.Db.Close()
 
+	err = datasource.AttachHolidays(&scheduleWithDetails)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get the holidays of the schedule")
+		return nil, err
+	}
+
 	if scheduleWithDetails.ID == "" {
-		stmt, err := sqlClient.Db.Prepare("INSERT INTO Schedule (id, nextReportTime, interval, name, description, lookback,reportGroupID,time,day) VALUES (?,?,?,?,?,?,?,?,?) RETURNING *")
+		stmt, err := sqlClient.Db.Prepare("INSERT INTO Schedule (id, nextReportTime, interval, name, description, lookback,reportGroupID,time,day,cronExpression,timeZone,retryMaxAttempts,retryBackoffSeconds,catchUpPolicy,startDate,endDate,holidayCalendarID,businessDayRule,fiscalYearStartMonth,format,inlineRows,templateID,summarySheet,layout) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?) RETURNING *")
 		if err != nil {
 			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not create schedule record")
 			return nil, err
@@ -50,16 +130,17 @@ func (datasource *MsupplyEresDatasource) CreateScheduleWithDetails(scheduleWithD
 		defer stmt.Close()
 
 		scheduleWithDetails.ID = uuid.New().String()
+		scheduleWithDetails.Enabled = true
 
 		scheduleWithDetails.UpdateNextReportTime()
 
-		_, err = stmt.Exec(scheduleWithDetails.ID, scheduleWithDetails.NextReportTime, scheduleWithDetails.Interval, scheduleWithDetails.Name, scheduleWithDetails.Description, scheduleWithDetails.Lookback, scheduleWithDetails.ReportGroupID, scheduleWithDetails.Time, scheduleWithDetails.Day)
+		_, err = stmt.Exec(scheduleWithDetails.ID, scheduleWithDetails.NextReportTime, scheduleWithDetails.Interval, scheduleWithDetails.Name, scheduleWithDetails.Description, scheduleWithDetails.Lookback, scheduleWithDetails.ReportGroupID, scheduleWithDetails.Time, scheduleWithDetails.Day, scheduleWithDetails.CronExpression, scheduleWithDetails.TimeZone, scheduleWithDetails.RetryMaxAttempts, scheduleWithDetails.RetryBackoffSeconds, scheduleWithDetails.CatchUpPolicy, scheduleWithDetails.StartDate, scheduleWithDetails.EndDate, scheduleWithDetails.HolidayCalendarID, scheduleWithDetails.BusinessDayRule, scheduleWithDetails.FiscalYearStartMonth, scheduleWithDetails.Format, scheduleWithDetails.InlineRows, scheduleWithDetails.TemplateID, scheduleWithDetails.SummarySheet, scheduleWithDetails.Layout)
 		if err != nil {
 			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not create schedule record")
 			return nil, err
 		}
 	} else {
-		stmt, err := sqlClient.Db.Prepare("UPDATE Schedule SET nextReportTime = ?, interval = ?, name = ?, description = ?, lookback = ?, reportGroupID = ?, time = ?, day = ? where id = ? RETURNING *")
+		stmt, err := sqlClient.Db.Prepare("UPDATE Schedule SET nextReportTime = ?, interval = ?, name = ?, description = ?, lookback = ?, reportGroupID = ?, time = ?, day = ?, cronExpression = ?, timeZone = ?, retryMaxAttempts = ?, retryBackoffSeconds = ?, retryAfter = 0, catchUpPolicy = ?, startDate = ?, endDate = ?, holidayCalendarID = ?, businessDayRule = ?, fiscalYearStartMonth = ?, format = ?, inlineRows = ?, templateID = ?, summarySheet = ?, layout = ? where id = ? RETURNING *")
 		if err != nil {
 			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update schedule record")
 			return nil, err
@@ -68,7 +149,7 @@ func (datasource *MsupplyEresDatasource) CreateScheduleWithDetails(scheduleWithD
 
 		scheduleWithDetails.UpdateNextReportTime()
 
-		_, err = stmt.Exec(scheduleWithDetails.NextReportTime, scheduleWithDetails.Interval, scheduleWithDetails.Name, scheduleWithDetails.Description, scheduleWithDetails.Lookback, scheduleWithDetails.ReportGroupID, scheduleWithDetails.Time, scheduleWithDetails.Day, scheduleWithDetails.ID)
+		_, err = stmt.Exec(scheduleWithDetails.NextReportTime, scheduleWithDetails.Interval, scheduleWithDetails.Name, scheduleWithDetails.Description, scheduleWithDetails.Lookback, scheduleWithDetails.ReportGroupID, scheduleWithDetails.Time, scheduleWithDetails.Day, scheduleWithDetails.CronExpression, scheduleWithDetails.TimeZone, scheduleWithDetails.RetryMaxAttempts, scheduleWithDetails.RetryBackoffSeconds, scheduleWithDetails.CatchUpPolicy, scheduleWithDetails.StartDate, scheduleWithDetails.EndDate, scheduleWithDetails.HolidayCalendarID, scheduleWithDetails.BusinessDayRule, scheduleWithDetails.FiscalYearStartMonth, scheduleWithDetails.Format, scheduleWithDetails.InlineRows, scheduleWithDetails.TemplateID, scheduleWithDetails.SummarySheet, scheduleWithDetails.Layout, scheduleWithDetails.ID)
 		if err != nil {
 			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update schedule record")
 			return nil, err
@@ -94,23 +175,165 @@ func (datasource *MsupplyEresDatasource) CreateScheduleWithDetails(scheduleWithD
 		return nil, err
 	}
 
+	err = datasource.SetScheduleDependencies(scheduleWithDetails.ID, scheduleWithDetails.DependsOn)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not update schedule dependencies")
+		return nil, err
+	}
+
 	return &scheduleWithDetails, nil
 }
 
 func (schedule *Schedule) UpdateNextReportTime() {
-	now := time.Now()
+	reportTime := schedule.firstReportTimeAfter(time.Now())
+	schedule.NextReportTime = int(reportTime.Unix())
+	log.DefaultLogger.Info(fmt.Sprintf("Setting time of schedule '%s' to '%s'", schedule.Name, reportTime))
+}
+
+// firstReportTimeAfter returns the report time the schedule would be given if it was saved at
+// now. A schedule which has not started yet sends its first report on or after its start date.
+func (schedule *Schedule) firstReportTimeAfter(now time.Time) time.Time {
+	if start := time.Unix(int64(schedule.StartDate), 0); schedule.StartDate > 0 && start.After(now) {
+		now = start
+	}
+
+	return schedule.NextReportTimeAfter(now)
+}
+
+// UpcomingReportTimes returns up to count of the report times the schedule will fire at, in
+// the schedule's time zone. A saved schedule starts from its next report time; an unsaved one
+// from the time it would be given if it was saved now. Report times after the end date are left out.
+func (schedule *Schedule) UpcomingReportTimes(now time.Time, count int) []time.Time {
+	upcoming := []time.Time{}
+	location := schedule.Location()
+
+	reportTime := schedule.firstReportTimeAfter(now)
+	if schedule.NextReportTime > 0 {
+		reportTime = time.Unix(int64(schedule.NextReportTime), 0)
+	}
+
+	for len(upcoming) < count && !reportTime.IsZero() {
+		if schedule.EndDate > 0 && reportTime.Unix() > int64(schedule.EndDate) {
+			break
+		}
+		upcoming = append(upcoming, reportTime.In(location))
+		reportTime = schedule.NextReportTimeAfter(reportTime.Add(time.Second))
+	}
+
+	return upcoming
+}
+
+// AdvanceNextReportTime moves the schedule on once it has run for its next report time. A
+// schedule catching up on every missed report time moves to the one after the time it ran
+// for, which may still be overdue; any other schedule moves to the first report time from now.
+func (schedule *Schedule) AdvanceNextReportTime() {
+	if schedule.CatchUpPolicy != CatchUpPolicyAll || schedule.NextReportTime <= 0 {
+		schedule.UpdateNextReportTime()
+		return
+	}
+
+	reportTime := schedule.NextReportTimeAfter(time.Unix(int64(schedule.NextReportTime)+1, 0))
+	schedule.NextReportTime = int(reportTime.Unix())
+	log.DefaultLogger.Info(fmt.Sprintf("Setting time of schedule '%s' to '%s'", schedule.Name, reportTime))
+}
+
+// MissedReportTimes returns the report times of the schedule from its next report time up to now,
+// keeping at most MAX_CATCH_UP_RUNS of the latest.
+func (schedule *Schedule) MissedReportTimes(now time.Time) []time.Time {
+	missed := []time.Time{}
+	if schedule.NextReportTime <= 0 {
+		return missed
+	}
+
+	reportTime := time.Unix(int64(schedule.NextReportTime), 0)
+	for !reportTime.After(now) {
+		if len(missed) == MAX_CATCH_UP_RUNS {
+			missed = missed[1:]
+		}
+		missed = append(missed, reportTime)
+		next := schedule.NextReportTimeAfter(reportTime.Add(time.Second))
+		if next.IsZero() {
+			break
+		}
+		reportTime = next
+	}
+
+	return missed
+}
+
+// MaxAttempts returns how many times a scheduled run is attempted, including the first, before
+// it is given up on.
+func (schedule *Schedule) MaxAttempts() int {
+	if schedule.RetryMaxAttempts <= 0 {
+		return DEFAULT_RETRY_MAX_ATTEMPTS
+	}
+	return schedule.RetryMaxAttempts
+}
+
+// RetryDelay returns how long to wait before retrying a run which has failed attempt times.
+func (schedule *Schedule) RetryDelay(attempt int) time.Duration {
+	backoff := schedule.RetryBackoffSeconds
+	if backoff <= 0 {
+		backoff = DEFAULT_RETRY_BACKOFF_SECONDS
+	}
+
+	delay := backoff
+	for i := 1; i < attempt && delay < MAX_RETRY_BACKOFF_SECONDS; i++ {
+		delay *= 2
+	}
+	if delay > MAX_RETRY_BACKOFF_SECONDS {
+		delay = MAX_RETRY_BACKOFF_SECONDS
+	}
+
+	return time.Duration(delay) * time.Second
+}
+
+// Location returns the time zone the schedule's times are expressed in, falling back to
+// the server's local time zone for schedules saved without one.
+func (schedule *Schedule) Location() *time.Location {
+	if schedule.TimeZone == "" {
+		return time.Local
+	}
+
+	location, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		log.DefaultLogger.Error(fmt.Sprintf("Invalid time zone '%s' for schedule '%s', using local time: %s", schedule.TimeZone, schedule.Name, err.Error()))
+		return time.Local
+	}
+
+	return location
+}
+
+// intervalReportTimeAfter returns the first time the schedule's cron expression, or its
+// Interval/Day/Time fields, fire at after now, before business days are taken into account.
+// A cron expression, when set, takes precedence over the Interval/Day/Time fields.
+func (schedule *Schedule) intervalReportTimeAfter(now time.Time) time.Time {
+	location := schedule.Location()
+	now = now.In(location)
+
+	if schedule.CronExpression != "" {
+		cronSchedule, err := cron.ParseStandard(schedule.CronExpression)
+		if err == nil {
+			return cronSchedule.Next(now)
+		}
+		log.DefaultLogger.Error(fmt.Sprintf("Invalid cron expression '%s' for schedule '%s', falling back to interval: %s", schedule.CronExpression, schedule.Name, err.Error()))
+	}
+
 	daysOffset := 1
 	scheduleDays := 1
 	if schedule.Day > 0 {
 		scheduleDays = schedule.Day
 	}
 
-	reportTime := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, now.Location())
+	// Day arithmetic is done on the wall clock, held in UTC so that daylight saving
+	// transitions cannot shift the date, and only placed in the schedule's time zone at the end.
+	wallClockNow := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), time.UTC)
+	reportTime := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, time.UTC)
 	// there is probably a better way to parse the time string, it didn't work for me though
 	timeOfDay, err := time.Parse(time.RFC3339, "1970-01-01T"+schedule.Time+":00+00:00")
 	if err == nil {
 		log.DefaultLogger.Info(fmt.Sprintf("Adding time of '%s' to '%s'", schedule.Time, reportTime))
-		reportTime = time.Date(reportTime.Year(), reportTime.Month(), reportTime.Day(), timeOfDay.Hour(), timeOfDay.Minute(), 0, 0, now.Location())
+		reportTime = time.Date(reportTime.Year(), reportTime.Month(), reportTime.Day(), timeOfDay.Hour(), timeOfDay.Minute(), 0, 0, time.UTC)
 	}
 
 	// for the intervals using x Day of y, remove the current Day value
@@ -152,11 +375,23 @@ func (schedule *Schedule) UpdateNextReportTime() {
 		}
 
 	default: // 0 == daily
-		if reportTime.Unix() < now.Unix() {
+		if reportTime.Before(wallClockNow) {
 			// run tomorrow
 			reportTime = reportTime.AddDate(0, 0, 1)
 		}
 	}
-	schedule.NextReportTime = int(reportTime.Unix())
-	log.DefaultLogger.Info(fmt.Sprintf("Setting time of schedule '%s' to '%s'", schedule.Name, reportTime))
+	return wallClockIn(reportTime, location)
+}
+
+// wallClockIn places the wall clock time held in the UTC time t into location. A time that
+// falls into a daylight saving gap is moved forward by the size of the gap, so it never lands
+// on the previous day, and an ambiguous time resolves to a single instant.
+func wallClockIn(t time.Time, location *time.Location) time.Time {
+	local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), location)
+	resolved := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+	if resolved.Before(t) {
+		local = local.Add(t.Sub(resolved))
+	}
+
+	return local
 }
@@ -0,0 +1,372 @@
+package datasource
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testTimeLayout = "2006-01-02 15:04"
+
+// inZone parses a wall clock time formatted as testTimeLayout in the named time zone.
+func inZone(t *testing.T, zone string, value string) time.Time {
+	t.Helper()
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q): %v", zone, err)
+	}
+	parsed, err := time.ParseInLocation(testTimeLayout, value, location)
+	if err != nil {
+		t.Fatalf("ParseInLocation(%q): %v", value, err)
+	}
+	return parsed
+}
+
+func TestNextReportTimeAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		now      string
+		want     string
+	}{
+		{
+			name:     "cron on weekdays skips the weekend",
+			schedule: Schedule{CronExpression: "0 9 * * 1-5", TimeZone: "UTC"},
+			now:      "2024-03-01 10:00",
+			want:     "2024-03-04 09:00",
+		},
+		{
+			name:     "cron on the first of the month",
+			schedule: Schedule{CronExpression: "30 6 1 * *", TimeZone: "UTC"},
+			now:      "2024-01-15 00:00",
+			want:     "2024-02-01 06:30",
+		},
+		{
+			name:     "cron every 15 minutes",
+			schedule: Schedule{CronExpression: "*/15 * * * *", TimeZone: "UTC"},
+			now:      "2024-01-15 10:07",
+			want:     "2024-01-15 10:15",
+		},
+		{
+			name:     "cron takes precedence over the interval",
+			schedule: Schedule{CronExpression: "0 12 * * *", Interval: 3, Day: 1, Time: "08:00", TimeZone: "UTC"},
+			now:      "2024-01-15 10:00",
+			want:     "2024-01-15 12:00",
+		},
+		{
+			name:     "invalid cron falls back to the interval",
+			schedule: Schedule{CronExpression: "bogus", Interval: 0, Time: "08:00", TimeZone: "UTC"},
+			now:      "2024-01-01 09:00",
+			want:     "2024-01-02 08:00",
+		},
+		{
+			name:     "daily later today",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC"},
+			now:      "2024-01-01 07:00",
+			want:     "2024-01-01 08:00",
+		},
+		{
+			name:     "daily tomorrow once today's time has passed",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC"},
+			now:      "2024-01-31 08:30",
+			want:     "2024-02-01 08:00",
+		},
+		{
+			name:     "weekly on Wednesday",
+			schedule: Schedule{Interval: 1, Day: 3, Time: "08:00", TimeZone: "UTC"},
+			now:      "2024-01-01 09:00",
+			want:     "2024-01-03 08:00",
+		},
+		{
+			name:     "weekly on the same weekday moves a week on",
+			schedule: Schedule{Interval: 1, Day: 3, Time: "08:00", TimeZone: "UTC"},
+			now:      "2024-01-03 09:00",
+			want:     "2024-01-10 08:00",
+		},
+		{
+			name:     "monthly on the 15th",
+			schedule: Schedule{Interval: 3, Day: 15, Time: "08:00", TimeZone: "UTC"},
+			now:      "2024-01-20 09:00",
+			want:     "2024-02-15 08:00",
+		},
+		{
+			name:     "quarterly on the 1st",
+			schedule: Schedule{Interval: 4, Day: 1, Time: "08:00", TimeZone: "UTC"},
+			now:      "2024-01-10 09:00",
+			want:     "2024-04-01 08:00",
+		},
+		{
+			name:     "yearly on the 1st",
+			schedule: Schedule{Interval: 5, Day: 1, Time: "08:00", TimeZone: "UTC"},
+			now:      "2024-01-10 09:00",
+			want:     "2025-01-01 08:00",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.schedule.NextReportTimeAfter(inZone(t, "UTC", test.now))
+			if want := inZone(t, "UTC", test.want); !got.Equal(want) {
+				t.Errorf("NextReportTimeAfter(%s) = %s, want %s", test.now, got.UTC().Format(testTimeLayout), test.want)
+			}
+		})
+	}
+}
+
+func TestNextReportTimeAfterInTimeZone(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		// now and want are wall clock times in the schedule's time zone
+		now  string
+		want string
+	}{
+		{
+			name:     "daily in Auckland",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "Pacific/Auckland"},
+			now:      "2024-06-01 12:00",
+			want:     "2024-06-02 08:00",
+		},
+		{
+			name:     "cron in New York",
+			schedule: Schedule{CronExpression: "0 8 * * *", TimeZone: "America/New_York"},
+			now:      "2024-01-01 07:00",
+			want:     "2024-01-01 08:00",
+		},
+		{
+			name:     "daily keeps its time when the clocks go forward",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "Europe/London"},
+			now:      "2024-03-30 09:00",
+			want:     "2024-03-31 08:00",
+		},
+		{
+			name:     "daily keeps its time when the clocks go back",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "Europe/London"},
+			now:      "2024-10-26 09:00",
+			want:     "2024-10-27 08:00",
+		},
+		{
+			name:     "weekly across the clocks going forward",
+			schedule: Schedule{Interval: 1, Day: 1, Time: "09:00", TimeZone: "Europe/London"},
+			now:      "2024-03-25 10:00",
+			want:     "2024-04-01 09:00",
+		},
+		{
+			name:     "a time in the daylight saving gap moves forward by the gap",
+			schedule: Schedule{Interval: 0, Time: "02:30", TimeZone: "America/New_York"},
+			now:      "2024-03-09 12:00",
+			want:     "2024-03-10 03:30",
+		},
+		{
+			name:     "a time repeated when the clocks go back stays on its day",
+			schedule: Schedule{Interval: 0, Time: "01:30", TimeZone: "America/New_York"},
+			now:      "2024-11-02 12:00",
+			want:     "2024-11-03 01:30",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.schedule.NextReportTimeAfter(inZone(t, test.schedule.TimeZone, test.now))
+			if local := got.In(test.schedule.Location()).Format(testTimeLayout); local != test.want {
+				t.Errorf("NextReportTimeAfter(%s) = %s, want %s", test.now, local, test.want)
+			}
+		})
+	}
+}
+
+func TestNextReportTimeAfterFromAnotherTimeZone(t *testing.T) {
+	// the schedule's time zone decides the day, not the time zone now is given in
+	schedule := Schedule{Interval: 0, Time: "08:00", TimeZone: "Pacific/Auckland"}
+	now := inZone(t, "UTC", "2024-06-01 00:00")
+
+	got := schedule.NextReportTimeAfter(now)
+	if want := inZone(t, "UTC", "2024-06-01 20:00"); !got.Equal(want) {
+		t.Errorf("NextReportTimeAfter(%s UTC) = %s UTC, want %s UTC", now.Format(testTimeLayout), got.UTC().Format(testTimeLayout), want.Format(testTimeLayout))
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		attempt  int
+		want     time.Duration
+	}{
+		{name: "first retry waits the backoff", schedule: Schedule{RetryBackoffSeconds: 60}, attempt: 1, want: time.Minute},
+		{name: "second retry waits twice as long", schedule: Schedule{RetryBackoffSeconds: 60}, attempt: 2, want: 2 * time.Minute},
+		{name: "fifth retry doubles four times", schedule: Schedule{RetryBackoffSeconds: 60}, attempt: 5, want: 16 * time.Minute},
+		{name: "no backoff uses the default", schedule: Schedule{}, attempt: 1, want: DEFAULT_RETRY_BACKOFF_SECONDS * time.Second},
+		{name: "default backoff doubles", schedule: Schedule{}, attempt: 3, want: 4 * DEFAULT_RETRY_BACKOFF_SECONDS * time.Second},
+		{name: "doubling stops at the cap", schedule: Schedule{}, attempt: 10, want: MAX_RETRY_BACKOFF_SECONDS * time.Second},
+		{name: "many attempts stay at the cap", schedule: Schedule{RetryBackoffSeconds: 60}, attempt: 100, want: MAX_RETRY_BACKOFF_SECONDS * time.Second},
+		{name: "a backoff over the cap is capped", schedule: Schedule{RetryBackoffSeconds: 2 * MAX_RETRY_BACKOFF_SECONDS}, attempt: 1, want: MAX_RETRY_BACKOFF_SECONDS * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.schedule.RetryDelay(test.attempt); got != test.want {
+				t.Errorf("RetryDelay(%d) = %s, want %s", test.attempt, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMaxAttempts(t *testing.T) {
+	tests := []struct {
+		retryMaxAttempts int
+		want             int
+	}{
+		{retryMaxAttempts: 0, want: DEFAULT_RETRY_MAX_ATTEMPTS},
+		{retryMaxAttempts: -1, want: DEFAULT_RETRY_MAX_ATTEMPTS},
+		{retryMaxAttempts: 1, want: 1},
+		{retryMaxAttempts: 5, want: 5},
+	}
+
+	for _, test := range tests {
+		schedule := Schedule{RetryMaxAttempts: test.retryMaxAttempts}
+		if got := schedule.MaxAttempts(); got != test.want {
+			t.Errorf("MaxAttempts with RetryMaxAttempts %d = %d, want %d", test.retryMaxAttempts, got, test.want)
+		}
+	}
+}
+
+func TestMissedReportTimes(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		next     string
+		now      string
+		count    int
+		first    string
+		last     string
+	}{
+		{
+			name:     "each day of a gap",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC"},
+			next:     "2024-01-01 08:00",
+			now:      "2024-01-05 09:00",
+			count:    5,
+			first:    "2024-01-01 08:00",
+			last:     "2024-01-05 08:00",
+		},
+		{
+			name:     "weekdays across a weekend",
+			schedule: Schedule{CronExpression: "0 9 * * 1-5", TimeZone: "UTC"},
+			next:     "2024-03-01 09:00",
+			now:      "2024-03-05 10:00",
+			count:    3,
+			first:    "2024-03-01 09:00",
+			last:     "2024-03-05 09:00",
+		},
+		{
+			name:     "only the latest are kept",
+			schedule: Schedule{CronExpression: "0 * * * *", TimeZone: "UTC"},
+			next:     "2024-01-01 00:00",
+			now:      "2024-01-11 00:30",
+			count:    MAX_CATCH_UP_RUNS,
+			first:    "2024-01-06 21:00",
+			last:     "2024-01-11 00:00",
+		},
+		{
+			name:     "next report time still to come",
+			schedule: Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC"},
+			next:     "2024-01-05 08:00",
+			now:      "2024-01-05 07:00",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.schedule.NextReportTime = int(inZone(t, "UTC", test.next).Unix())
+			missed := test.schedule.MissedReportTimes(inZone(t, "UTC", test.now))
+			if len(missed) != test.count {
+				t.Fatalf("MissedReportTimes returned %d report times, want %d", len(missed), test.count)
+			}
+			if test.count == 0 {
+				return
+			}
+			if first := missed[0].UTC().Format(testTimeLayout); first != test.first {
+				t.Errorf("the first missed report time is %s, want %s", first, test.first)
+			}
+			if last := missed[len(missed)-1].UTC().Format(testTimeLayout); last != test.last {
+				t.Errorf("the last missed report time is %s, want %s", last, test.last)
+			}
+		})
+	}
+}
+
+func TestMissedReportTimesOfUnsavedSchedule(t *testing.T) {
+	schedule := Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC"}
+	if missed := schedule.MissedReportTimes(inZone(t, "UTC", "2024-01-05 09:00")); len(missed) != 0 {
+		t.Errorf("MissedReportTimes returned %d report times for a schedule without a next report time", len(missed))
+	}
+}
+
+func TestUpcomingReportTimes(t *testing.T) {
+	daily := Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC"}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		next     string
+		endDate  string
+		count    int
+		want     []string
+	}{
+		{
+			name:     "the next report times of an unsaved schedule",
+			schedule: daily,
+			count:    3,
+			want:     []string{"2024-05-15 08:00", "2024-05-16 08:00", "2024-05-17 08:00"},
+		},
+		{
+			name:     "a saved schedule starts from its next report time",
+			schedule: daily,
+			next:     "2024-05-20 08:00",
+			count:    2,
+			want:     []string{"2024-05-20 08:00", "2024-05-21 08:00"},
+		},
+		{
+			name:     "report times after the end date are left out",
+			schedule: daily,
+			endDate:  "2024-05-16 12:00",
+			count:    5,
+			want:     []string{"2024-05-15 08:00", "2024-05-16 08:00"},
+		},
+		{
+			name:     "a report time on the end date is kept",
+			schedule: daily,
+			endDate:  "2024-05-16 08:00",
+			count:    5,
+			want:     []string{"2024-05-15 08:00", "2024-05-16 08:00"},
+		},
+		{
+			name:     "an end date already passed leaves none",
+			schedule: daily,
+			endDate:  "2024-05-01 08:00",
+			count:    5,
+			want:     []string{},
+		},
+	}
+
+	now := inZone(t, "UTC", "2024-05-15 07:00")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.next != "" {
+				test.schedule.NextReportTime = int(inZone(t, "UTC", test.next).Unix())
+			}
+			if test.endDate != "" {
+				test.schedule.EndDate = int(inZone(t, "UTC", test.endDate).Unix())
+			}
+
+			got := []string{}
+			for _, reportTime := range test.schedule.UpcomingReportTimes(now, test.count) {
+				got = append(got, reportTime.Format(testTimeLayout))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("UpcomingReportTimes = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -44,28 +44,28 @@ func (datasource *MsupplyEresDatasource) CreateOrUpdateSettings(settings setting
 			emailPassword = settings.EmailPassword
 		}
 
-		stmt, err := db.Prepare("UPDATE Config set id = ?, grafanaUsername = ?, grafanaPassword = ?, email = ?, emailPassword = ?, datasourceID = ?, emailHost = ?, emailPort = ?, grafanaURL = ?")
+		stmt, err := db.Prepare("UPDATE Config set id = ?, grafanaUsername = ?, grafanaPassword = ?, email = ?, emailPassword = ?, datasourceID = ?, emailHost = ?, emailPort = ?, grafanaURL = ?, reportWorkers = ?")
 		if err != nil {
 			log.DefaultLogger.Error("CreateOrUpdateSettings: db.Prepare()1: ", err.Error())
 			return err
 		}
 		defer stmt.Close()
 
-		_, err = stmt.Exec("ID", settings.GrafanaUsername, grafanPassword, settings.Email, emailPassword, settings.DatasourceID, settings.EmailHost, settings.EmailPort, settings.GrafanaURL)
+		_, err = stmt.Exec("ID", settings.GrafanaUsername, grafanPassword, settings.Email, emailPassword, settings.DatasourceID, settings.EmailHost, settings.EmailPort, settings.GrafanaURL, settings.ReportWorkers)
 		if err != nil {
 			log.DefaultLogger.Error("CreateOrUpdateSettings: stmt.Exec()2: ", err.Error())
 			return err
 		}
 
 	} else {
-		stmt, err := db.Prepare("INSERT INTO Config (id, grafanaUsername, grafanaPassword, email, emailPassword, datasourceID, emailHost, emailPort, grafanaURL) VALUES (?,?,?,?,?,?,?,?,?)")
+		stmt, err := db.Prepare("INSERT INTO Config (id, grafanaUsername, grafanaPassword, email, emailPassword, datasourceID, emailHost, emailPort, grafanaURL, reportWorkers) VALUES (?,?,?,?,?,?,?,?,?,?)")
 		if err != nil {
 			log.DefaultLogger.Error("CreateOrUpdateSettings: db.Prepare()2: ", err.Error())
 			return err
 		}
 		defer stmt.Close()
 
-		_, err = stmt.Exec("ID", settings.GrafanaUsername, settings.GrafanaPassword, settings.Email, settings.EmailPassword, settings.DatasourceID, settings.EmailHost, settings.EmailPort, settings.GrafanaURL)
+		_, err = stmt.Exec("ID", settings.GrafanaUsername, settings.GrafanaPassword, settings.Email, settings.EmailPassword, settings.DatasourceID, settings.EmailHost, settings.EmailPort, settings.GrafanaURL, settings.ReportWorkers)
 		if err != nil {
 			log.DefaultLogger.Error("CreateOrUpdateSettings: stmt.Exec(): ", err.Error())
 			return err
@@ -85,7 +85,7 @@ func (datasource *MsupplyEresDatasource) NewSettings() (*setting.Settings, error
 	defer sqlClient.Db.Close()
 
 	var id, grafanaUsername, grafanaPassword, email, emailPassword, emailHost, grafanaURL string
-	var emailPort, datasourceID int
+	var emailPort, datasourceID, reportWorkers int
 
 	exists, err := datasource.settingsExists()
 	if err != nil {
@@ -94,7 +94,7 @@ func (datasource *MsupplyEresDatasource) NewSettings() (*setting.Settings, error
 	}
 
 	if exists {
-		rows, err := sqlClient.Db.Query("SELECT id, grafanaUsername, grafanaPassword, email, emailPassword, datasourceID, emailHost, emailPort, grafanaURL FROM Config")
+		rows, err := sqlClient.Db.Query("SELECT id, grafanaUsername, grafanaPassword, email, emailPassword, datasourceID, emailHost, emailPort, grafanaURL, reportWorkers FROM Config")
 		if err != nil {
 			log.DefaultLogger.Error("GetSettings: db.Query(): ", err.Error())
 			return nil, err
@@ -102,16 +102,16 @@ func (datasource *MsupplyEresDatasource) NewSettings() (*setting.Settings, error
 		defer rows.Close()
 
 		rows.Next()
-		err = rows.Scan(&id, &grafanaUsername, &grafanaPassword, &email, &emailPassword, &datasourceID, &emailHost, &emailPort, &grafanaURL)
+		err = rows.Scan(&id, &grafanaUsername, &grafanaPassword, &email, &emailPassword, &datasourceID, &emailHost, &emailPort, &grafanaURL, &reportWorkers)
 		if err != nil {
 			log.DefaultLogger.Error("GetSettings: rows.Scan(): ", err.Error())
 			return nil, err
 		}
 
-		return &setting.Settings{GrafanaUsername: grafanaUsername, GrafanaPassword: grafanaPassword, Email: email, EmailPassword: emailPassword, DatasourceID: datasourceID, EmailPort: emailPort, EmailHost: emailHost, GrafanaURL: grafanaURL}, nil
+		return &setting.Settings{GrafanaUsername: grafanaUsername, GrafanaPassword: grafanaPassword, Email: email, EmailPassword: emailPassword, DatasourceID: datasourceID, EmailPort: emailPort, EmailHost: emailHost, GrafanaURL: grafanaURL, ReportWorkers: reportWorkers}, nil
 	}
 
-	return &setting.Settings{GrafanaUsername: grafanaUsername, GrafanaPassword: grafanaPassword, Email: email, EmailPassword: emailPassword, DatasourceID: datasourceID, EmailPort: emailPort, EmailHost: emailHost, GrafanaURL: grafanaURL}, nil
+	return &setting.Settings{GrafanaUsername: grafanaUsername, GrafanaPassword: grafanaPassword, Email: email, EmailPassword: emailPassword, DatasourceID: datasourceID, EmailPort: emailPort, EmailHost: emailHost, GrafanaURL: grafanaURL, ReportWorkers: reportWorkers}, nil
 }
 
 func (datasource *MsupplyEresDatasource) settingsExists() (bool, error) {
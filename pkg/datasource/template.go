@@ -0,0 +1,167 @@
+package datasource
+
+import (
+	"database/sql"
+	"excel-report-email-scheduler/pkg/ereserror"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Template is an uploaded Excel template which schedules can build their reports from. The
+// workbook itself is kept in the data directory, named after the template's ID.
+type Template struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// FileName is the name of the file the template was uploaded from
+	FileName   string `json:"fileName"`
+	UploadedAt int    `json:"uploadedAt"`
+}
+
+func TemplateFields() string {
+	return "\n{\n\tid string" +
+		"\n\tname string" +
+		"\n\tfileName string" +
+		"\n\tcontent string (the xlsx file, base64 encoded)\n}"
+}
+
+func (datasource *MsupplyEresDatasource) GetTemplates() ([]Template, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	rows, err := sqlClient.Db.Query("SELECT id, name, fileName, uploadedAt FROM Template ORDER BY name")
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get template list")
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []Template{}
+	for rows.Next() {
+		var template Template
+		err = rows.Scan(&template.ID, &template.Name, &template.FileName, &template.UploadedAt)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not scan template rows")
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, nil
+}
+
+func (datasource *MsupplyEresDatasource) GetTemplate(id string) (*Template, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	var template Template
+	row := sqlClient.Db.QueryRow("SELECT id, name, fileName, uploadedAt FROM Template WHERE id = ?", id)
+	err = row.Scan(&template.ID, &template.Name, &template.FileName, &template.UploadedAt)
+	if err == sql.ErrNoRows {
+		err = ereserror.New(404, errors.Wrap(err, frame.Function), "Could not find template with id: "+id)
+		return nil, err
+	}
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get template")
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// CreateTemplate saves the details of a newly uploaded template, or of a template which has
+// been uploaded again, giving a new template its ID.
+func (datasource *MsupplyEresDatasource) CreateTemplate(template Template) (*Template, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	template.UploadedAt = int(time.Now().Unix())
+	if template.ID == "" {
+		template.ID = uuid.New().String()
+		_, err = sqlClient.Db.Exec("INSERT INTO Template (id, name, fileName, uploadedAt) VALUES (?,?,?,?)", template.ID, template.Name, template.FileName, template.UploadedAt)
+	} else {
+		_, err = sqlClient.Db.Exec("UPDATE Template SET name = ?, fileName = ?, uploadedAt = ? WHERE id = ?", template.Name, template.FileName, template.UploadedAt, template.ID)
+	}
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not save template")
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// SchedulesUsingTemplate returns the names of the schedules whose reports are built from the template.
+func (datasource *MsupplyEresDatasource) SchedulesUsingTemplate(id string) ([]string, error) {
+	frame := trace()
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return nil, err
+	}
+	defer sqlClient.Db.Close()
+
+	rows, err := sqlClient.Db.Query("SELECT name FROM Schedule WHERE templateID = ? ORDER BY name", id)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not get the schedules using the template")
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not scan schedule rows")
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// DeleteTemplate deletes the template's details. Templates which schedules still use are not deleted.
+func (datasource *MsupplyEresDatasource) DeleteTemplate(id string) error {
+	frame := trace()
+	schedules, err := datasource.SchedulesUsingTemplate(id)
+	if err != nil {
+		return err
+	}
+	if len(schedules) > 0 {
+		err = errors.New("template is used by schedules")
+		err = ereserror.New(409, errors.Wrap(err, frame.Function), "The template is still used by the schedules: "+strings.Join(schedules, ", "))
+		return err
+	}
+
+	sqlClient, err := datasource.NewSqlClient()
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not open database")
+		return err
+	}
+	defer sqlClient.Db.Close()
+
+	_, err = sqlClient.Db.Exec("DELETE FROM Template WHERE id = ?", id)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not delete template")
+		return err
+	}
+
+	return nil
+}
@@ -4,6 +4,11 @@ import (
 	"excel-report-email-scheduler/pkg/datasource"
 	reportEmailer "excel-report-email-scheduler/pkg/report-emailer"
 	"excel-report-email-scheduler/pkg/server"
+	"os"
+	"os/signal"
+	"syscall"
+	// Schedules are expressed in IANA time zones, which are not available on every host (e.g. Windows)
+	_ "time/tzdata"
 
 	"github.com/bugsnag/bugsnag-go"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -22,13 +27,20 @@ func main() {
 
 	pluginLogger := log.New()
 
-	ds, server, err := Init(pluginLogger)
+	ds, re, server, err := Init(pluginLogger)
 	if err != nil {
 		pluginLogger.Error("Error starting mSupply Excel report e-mail scheduler datasource", "error", err.Error())
 		return
 	}
 
-	reportEmailer.NewReportEmailer(ds)
+	// Let reports being generated finish when Grafana stops the plugin
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM)
+		<-signals
+		re.Shutdown()
+		os.Exit(0)
+	}()
 
 	pluginLogger.Debug("Starting mSupply Excel report e-mail scheduler datasource")
 	err = backend.Serve(backend.ServeOpts{
@@ -39,12 +51,16 @@ func main() {
 	if err != nil {
 		pluginLogger.Error("Error starting mSupply Excel report e-mail scheduler datasource", "error", err.Error())
 	}
+
+	re.Shutdown()
 }
 
-func Init(logger log.Logger) (*datasource.MsupplyEresDatasource, *server.HttpServer, error) {
+func Init(logger log.Logger) (*datasource.MsupplyEresDatasource, *reportEmailer.ReportEmailer, *server.HttpServer, error) {
 	mSupplyEresDatasource, _ := datasource.NewMsupplyEresDatasource()
 
-	server := server.NewServer(mSupplyEresDatasource)
+	re := reportEmailer.NewReportEmailer(mSupplyEresDatasource)
+
+	server := server.NewServer(mSupplyEresDatasource, re)
 
-	return mSupplyEresDatasource, server, nil
+	return mSupplyEresDatasource, re, server, nil
 }
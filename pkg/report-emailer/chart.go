@@ -0,0 +1,114 @@
+package reportEmailer
+
+import (
+	"excel-report-email-scheduler/pkg/api"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	CHART_WIDTH  = 720
+	CHART_HEIGHT = 360
+)
+
+// chartColumns gives the time columns of a chart a date and time unit when they have none, so
+// they are written as times the chart can draw rather than as days.
+func chartColumns(columns []api.Column) []api.Column {
+	chartColumns := make([]api.Column, len(columns))
+	for i, column := range columns {
+		if column.Type == "time" && column.Unit == "" {
+			column.Unit = "dateTimeAsIso"
+		}
+		chartColumns[i] = column
+	}
+	return chartColumns
+}
+
+// chartType returns the type of Excel chart which draws the panel as the dashboard does.
+func chartType(panel api.TablePanel) excelize.ChartType {
+	if panel.Type == api.PanelTypeBarChart {
+		if panel.Chart.Orientation == "horizontal" {
+			return excelize.Bar
+		}
+		return excelize.Col
+	}
+
+	if panel.Chart.DrawStyle == "bars" {
+		return excelize.Col
+	}
+	return excelize.Line
+}
+
+// categoryColumn returns the column the chart's values are drawn against: the time of a time
+// series, or the field a bar chart's bars are labelled by. It returns -1 when there is none.
+func categoryColumn(panel api.TablePanel, columns []api.Column) int {
+	for i, column := range columns {
+		if panel.Type == api.PanelTypeTimeSeries && column.Type == "time" {
+			return i
+		}
+		if panel.Type == api.PanelTypeBarChart && panel.Chart.XField != "" && column.Text == panel.Chart.XField {
+			return i
+		}
+	}
+
+	if panel.Type == api.PanelTypeBarChart {
+		for i, column := range columns {
+			if column.Type != "number" {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// chartRef returns a chart's reference to the cells of a column, from the first row to the last.
+func chartRef(sheetName string, columnNumber int, firstRow int, lastRow int) string {
+	ref := sheetCellRef(sheetName, "$"+intToCol(columnNumber)+"$"+strconv.Itoa(firstRow))
+	if lastRow > firstRow {
+		ref += ":$" + intToCol(columnNumber) + "$" + strconv.Itoa(lastRow)
+	}
+	return ref
+}
+
+// addChart adds a native chart of a chart panel's table beside it, with a series for each
+// column of numbers, named by the column's header.
+func (r *Report) addChart(sheetName string, panel api.TablePanel, columns []api.Column, headersRow int, rowsRow int) error {
+	if len(panel.Rows) == 0 {
+		return nil
+	}
+
+	lastRow := rowsRow + len(panel.Rows) - 1
+	category := categoryColumn(panel, columns)
+
+	series := []excelize.ChartSeries{}
+	for i, column := range columns {
+		if i == category || column.Type != "number" {
+			continue
+		}
+
+		chartSeries := excelize.ChartSeries{
+			Name:   chartRef(sheetName, i, headersRow, headersRow),
+			Values: chartRef(sheetName, i, rowsRow, lastRow),
+		}
+		if category >= 0 {
+			chartSeries.Categories = chartRef(sheetName, category, rowsRow, lastRow)
+		}
+		series = append(series, chartSeries)
+	}
+	if len(series) == 0 {
+		log.DefaultLogger.Info("addChart: " + panel.Title + " has no columns of numbers to draw")
+		return nil
+	}
+
+	return r.file.AddChart(sheetName, r.createCellRef(len(columns)+1, headersRow), &excelize.Chart{
+		Type:         chartType(panel),
+		Series:       series,
+		Title:        []excelize.RichTextRun{{Text: panel.Title}},
+		Legend:       excelize.ChartLegend{Position: "bottom"},
+		Dimension:    excelize.ChartDimension{Width: CHART_WIDTH, Height: CHART_HEIGHT},
+		ShowBlanksAs: "gap",
+	})
+}
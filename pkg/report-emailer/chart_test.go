@@ -0,0 +1,153 @@
+package reportEmailer
+
+import (
+	"archive/zip"
+	"bytes"
+	"html"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"excel-report-email-scheduler/pkg/api"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// packagePart returns the text of a part of the saved workbook, such as xl/charts/chart1.xml,
+// or an empty string when there is no such part.
+func packagePart(t *testing.T, file *excelize.File, name string) string {
+	t.Helper()
+	buffer, err := file.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range archive.File {
+		if part.Name != name {
+			continue
+		}
+		reader, err := part.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reader.Close()
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return html.UnescapeString(string(content))
+	}
+	return ""
+}
+
+func TestAddChart(t *testing.T) {
+	timeSeries := api.TablePanel{
+		Title: "Stock",
+		Type:  api.PanelTypeTimeSeries,
+		Rows:  [][]interface{}{{1000.0, 1.0, 2.0}, {2000.0, 3.0, 4.0}, {3000.0, 5.0, 6.0}},
+	}
+	barChart := api.TablePanel{
+		Title: "Stock",
+		Type:  api.PanelTypeBarChart,
+		Chart: api.ChartOptions{XField: "store", Orientation: "horizontal"},
+		Rows:  [][]interface{}{{10.0, "Central", 1.0}, {20.0, "North", 2.0}},
+	}
+
+	tests := []struct {
+		name    string
+		panel   api.TablePanel
+		columns []api.Column
+		want    []string
+		noChart bool
+	}{
+		{
+			name:    "time series",
+			panel:   timeSeries,
+			columns: []api.Column{{Text: "time", Type: "time"}, {Text: "Central", Type: "number"}, {Text: "North", Type: "number"}},
+			want: []string{
+				"<lineChart>",
+				"<tx><strRef><f>'Stock'!$B$3</f>",
+				"<val><numRef><f>'Stock'!$B$4:$B$6</f>",
+				"<tx><strRef><f>'Stock'!$C$3</f>",
+				"<val><numRef><f>'Stock'!$C$4:$C$6</f>",
+				"<cat><strRef><f>'Stock'!$A$4:$A$6</f>",
+			},
+		},
+		{
+			name:    "bar chart by its x field",
+			panel:   barChart,
+			columns: []api.Column{{Text: "code", Type: "number"}, {Text: "store", Type: "string"}, {Text: "stock", Type: "number"}},
+			want: []string{
+				`<barDir val="bar">`,
+				"<val><numRef><f>'Stock'!$A$4:$A$5</f>",
+				"<val><numRef><f>'Stock'!$C$4:$C$5</f>",
+				"<cat><strRef><f>'Stock'!$B$4:$B$5</f>",
+			},
+		},
+		{
+			name:    "no columns of numbers",
+			panel:   timeSeries,
+			columns: []api.Column{{Text: "time", Type: "time"}, {Text: "store", Type: "string"}},
+			noChart: true,
+		},
+		{
+			name:    "no rows",
+			panel:   api.TablePanel{Title: "Stock", Type: api.PanelTypeTimeSeries},
+			columns: []api.Column{{Text: "time", Type: "time"}, {Text: "Central", Type: "number"}},
+			noChart: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := excelize.NewFile()
+			defer file.Close()
+			if err := file.SetSheetName("Sheet1", "Stock"); err != nil {
+				t.Fatal(err)
+			}
+
+			r := &Report{file: file, location: time.UTC}
+			if err := r.addChart("Stock", test.panel, test.columns, 3, 4); err != nil {
+				t.Fatalf("addChart returned an error: %v", err)
+			}
+
+			chart := packagePart(t, file, "xl/charts/chart1.xml")
+			if test.noChart {
+				if chart != "" {
+					t.Errorf("addChart added a chart, want none")
+				}
+				return
+			}
+			if chart == "" {
+				t.Fatalf("addChart added no chart")
+			}
+			for _, want := range test.want {
+				if !strings.Contains(chart, want) {
+					t.Errorf("the chart does not contain %s", want)
+				}
+			}
+		})
+	}
+}
+
+func TestAddChartBesideTable(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	r := &Report{file: file, location: time.UTC}
+	panel := api.TablePanel{Title: "Stock", Type: api.PanelTypeTimeSeries, Rows: [][]interface{}{{1000.0, 1.0}}}
+	columns := []api.Column{{Text: "time", Type: "time"}, {Text: "Central", Type: "number"}}
+	if err := r.addChart("Sheet1", panel, columns, 3, 4); err != nil {
+		t.Fatalf("addChart returned an error: %v", err)
+	}
+
+	// one column is left empty between the table and the chart, which starts on the headers' row
+	drawing := packagePart(t, file, "xl/drawings/drawing1.xml")
+	if !strings.Contains(drawing, "<xdr:from><xdr:col>3</xdr:col>") || !strings.Contains(drawing, "<xdr:row>2</xdr:row>") {
+		t.Errorf("the chart is not anchored at D3: %s", drawing)
+	}
+}
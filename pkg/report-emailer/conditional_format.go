@@ -0,0 +1,327 @@
+package reportEmailer
+
+import (
+	"excel-report-email-scheduler/pkg/api"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/xuri/excelize/v2"
+)
+
+// GRAFANA_COLORS are the colours of Grafana's palette, which thresholds and value mappings
+// name rather than giving a hex colour.
+var GRAFANA_COLORS = map[string]string{}
+
+var RGB_COLOR_REG = regexp.MustCompile(`^rgba?\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)`)
+
+func init() {
+	shades := []string{"super-light-", "light-", "", "semi-dark-", "dark-"}
+	for hue, colors := range map[string][]string{
+		"red":    {"#FFA6B0", "#FF7383", "#F2495C", "#E02F44", "#C4162A"},
+		"orange": {"#FFCB7D", "#FFB357", "#FF9830", "#FF780A", "#FA6400"},
+		"yellow": {"#FFF899", "#FFEE52", "#FADE2A", "#F2CC0C", "#E0B400"},
+		"green":  {"#C8F2C2", "#96D98D", "#73BF69", "#56A64B", "#37872D"},
+		"blue":   {"#C0D8FF", "#8AB8FF", "#5794F2", "#3274D9", "#1F60C4"},
+		"purple": {"#DEB6F2", "#CA95E5", "#B877D9", "#A352CC", "#8F3BB8"},
+	} {
+		for i, shade := range shades {
+			GRAFANA_COLORS[shade+hue] = colors[i]
+		}
+	}
+}
+
+// excelColor converts a colour of the dashboard to the hex colour Excel uses. It reports false
+// for colours which do not change how a cell looks, such as transparent and text.
+func excelColor(color string) (string, bool) {
+	color = strings.TrimSpace(color)
+	if hex, ok := GRAFANA_COLORS[color]; ok {
+		return hex, true
+	}
+	if strings.HasPrefix(color, "#") && (len(color) == 7 || len(color) == 9) {
+		return strings.ToUpper(color[:7]), true
+	}
+	if match := RGB_COLOR_REG.FindStringSubmatch(color); match != nil {
+		hex := "#"
+		for _, part := range match[1:] {
+			value, _ := strconv.Atoi(part)
+			hex += fmt.Sprintf("%02X", value%256)
+		}
+		return hex, true
+	}
+
+	return "", false
+}
+
+// contrastingTextColor returns black or white, whichever is easier to read on the background.
+func contrastingTextColor(background string) string {
+	red, _ := strconv.ParseUint(background[1:3], 16, 8)
+	green, _ := strconv.ParseUint(background[3:5], 16, 8)
+	blue, _ := strconv.ParseUint(background[5:7], 16, 8)
+	if 0.299*float64(red)+0.587*float64(green)+0.114*float64(blue) > 150 {
+		return "#000000"
+	}
+	return "#FFFFFF"
+}
+
+// formatRule is a conditional format rule: a formula for the first cell of the column, and
+// the colour and text of the cells it is true for.
+type formatRule struct {
+	formula string
+	color   string
+	text    string
+	index   int
+}
+
+// formulaText quotes text for a formula.
+func formulaText(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+}
+
+func formulaNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+// mappingRules turns the column's value mappings into rules for the cell, in the order the
+// dashboard checks them. Regex mappings are left out, as Excel's formulas have no regular
+// expressions.
+func mappingRules(cell string, mappings []api.ValueMapping) []formatRule {
+	rules := []formatRule{}
+	for _, mapping := range mappings {
+		switch mapping.Type {
+		case "value":
+			values := make([]string, 0, len(mapping.Values))
+			for value := range mapping.Values {
+				values = append(values, value)
+			}
+			sort.Strings(values)
+
+			for _, value := range values {
+				result := mapping.Values[value]
+				formula := "EXACT(" + cell + "," + formulaText(value) + ")"
+				if number, err := strconv.ParseFloat(value, 64); err == nil {
+					formula = "AND(ISNUMBER(" + cell + ")," + cell + "=" + formulaNumber(number) + ")"
+				}
+				rules = append(rules, formatRule{formula: formula, color: result.Color, text: result.Text, index: result.Index})
+			}
+		case "range":
+			conditions := []string{"ISNUMBER(" + cell + ")"}
+			if mapping.From != nil {
+				conditions = append(conditions, cell+">="+formulaNumber(*mapping.From))
+			}
+			if mapping.To != nil {
+				conditions = append(conditions, cell+"<="+formulaNumber(*mapping.To))
+			}
+			formula := "AND(" + strings.Join(conditions, ",") + ")"
+			rules = append(rules, formatRule{formula: formula, color: mapping.Result.Color, text: mapping.Result.Text, index: mapping.Result.Index})
+		case "special":
+			formula := ""
+			switch mapping.Match {
+			case "null", "null+nan":
+				formula = "ISBLANK(" + cell + ")"
+			case "empty":
+				formula = cell + `=""`
+			case "true", "false":
+				formula = cell + "=" + strings.ToUpper(mapping.Match)
+			}
+			if formula != "" {
+				rules = append(rules, formatRule{formula: formula, color: mapping.Result.Color, text: mapping.Result.Text, index: mapping.Result.Index})
+			}
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].index < rules[j].index })
+	return rules
+}
+
+// thresholdRules turns the column's thresholds into rules for the cell, highest step first.
+// The steps of percentage thresholds are found from the range of the column's values.
+func thresholdRules(cell string, thresholds api.Thresholds, values []float64) []formatRule {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		min, max = math.Min(min, value), math.Max(max, value)
+	}
+
+	rules := []formatRule{}
+	for _, step := range thresholds.Steps {
+		if step.Unusable {
+			continue
+		}
+
+		formula := "ISNUMBER(" + cell + ")"
+		if step.Value != nil {
+			value := *step.Value
+			if thresholds.Mode == "percentage" {
+				// when every value is the same the dashboard puts them all at 0%, so only the
+				// steps at or below 0% are reached
+				if len(values) == 0 || (max == min && value > 0) {
+					continue
+				}
+				value = min + (max-min)*value/100
+			}
+			formula = "AND(ISNUMBER(" + cell + ")," + cell + ">=" + formulaNumber(value) + ")"
+		}
+		rules = append([]formatRule{{formula: formula, color: step.Color}}, rules...)
+	}
+
+	return rules
+}
+
+// rangeCoordinates returns the first and last columns and rows of a range of cells, such as
+// A5:ZZ9999, or of a single cell.
+func rangeCoordinates(cells string) (int, int, int, int, error) {
+	refs := strings.Split(cells, ":")
+	startColumn, start, err := excelize.CellNameToCoordinates(refs[0])
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	endColumn, end := startColumn, start
+	if len(refs) > 1 {
+		if endColumn, end, err = excelize.CellNameToCoordinates(refs[1]); err != nil {
+			return 0, 0, 0, 0, err
+		}
+	}
+	return startColumn, start, endColumn, end, nil
+}
+
+// tableConditionalFormats removes the template's conditional formats which cover the first
+// row of the table, such as banded rows, and returns them with their ranges stretched to the
+// last row of the table.
+func (r *Report) tableConditionalFormats(sheetName string, firstRow int, lastRow int) (map[string][]excelize.ConditionalFormatOptions, error) {
+	formats, err := r.file.GetConditionalFormats(sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	tableFormats := make(map[string][]excelize.ConditionalFormatOptions)
+	for ranges, options := range formats {
+		covers := false
+		stretched := []string{}
+		for _, cells := range strings.Fields(ranges) {
+			startColumn, start, endColumn, end, err := rangeCoordinates(cells)
+			if err != nil {
+				return nil, err
+			}
+
+			if start <= firstRow && firstRow <= end {
+				covers = true
+				end = int(math.Max(float64(end), float64(lastRow)))
+			}
+			stretched = append(stretched, r.createCellRef(startColumn-1, start)+":"+r.createCellRef(endColumn-1, end))
+		}
+
+		if covers {
+			if err := r.file.UnsetConditionalFormat(sheetName, ranges); err != nil {
+				return nil, err
+			}
+			tableFormats[strings.Join(stretched, " ")] = options
+		}
+	}
+
+	return tableFormats, nil
+}
+
+// setConditionalFormats colours the cells of each column of a table as the dashboard does,
+// and shows the text of their value mappings, using conditional formats so the values
+// themselves are kept. Rules set first win, so value mappings come before thresholds, and
+// both before the template's own formats for the table.
+func (r *Report) setConditionalFormats(sheetName string, columns []api.Column, rows [][]interface{}, firstRow int) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	templateFormats, err := r.tableConditionalFormats(sheetName, firstRow, firstRow+len(rows)-1)
+	if err != nil {
+		log.DefaultLogger.Error("setConditionalFormats: tableConditionalFormats: " + err.Error())
+		return err
+	}
+
+	if err := r.setColumnFormats(sheetName, columns, rows, firstRow); err != nil {
+		return err
+	}
+
+	return r.addConditionalFormats(sheetName, templateFormats)
+}
+
+// setColumnFormats adds the conditional formats for the value mappings and thresholds of each
+// column of a table whose rows start at firstRow.
+func (r *Report) setColumnFormats(sheetName string, columns []api.Column, rows [][]interface{}, firstRow int) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	lastRow := firstRow + len(rows) - 1
+	for i, column := range columns {
+		cell := r.createCellRef(i, firstRow)
+		rules := mappingRules(cell, column.Mappings)
+		if column.ColorMode != "" {
+			values := []float64{}
+			for _, row := range rows {
+				if i < len(row) {
+					if value, ok := row[i].(float64); ok {
+						values = append(values, value)
+					}
+				}
+			}
+			rules = append(rules, thresholdRules(cell, column.Thresholds, values)...)
+		}
+
+		options := []excelize.ConditionalFormatOptions{}
+		for _, rule := range rules {
+			style := excelize.Style{}
+			if color, ok := excelColor(rule.color); ok && column.ColorMode == api.ColorModeBackground {
+				style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{color}}
+				style.Font = &excelize.Font{Color: contrastingTextColor(color)}
+			} else if ok && column.ColorMode == api.ColorModeText {
+				style.Font = &excelize.Font{Color: color}
+			}
+			if rule.text != "" {
+				text := quoteFormatText(rule.text)
+				numFmt := strings.Join([]string{text, text, text, text}, ";")
+				style.CustomNumFmt = &numFmt
+			}
+			if style.Font == nil && style.CustomNumFmt == nil {
+				continue
+			}
+
+			format, err := r.file.NewConditionalStyle(&style)
+			if err != nil {
+				return err
+			}
+			options = append(options, excelize.ConditionalFormatOptions{Type: "formula", Criteria: rule.formula, Format: format})
+		}
+		if len(options) == 0 {
+			continue
+		}
+
+		cells := cell + ":" + r.createCellRef(i, lastRow)
+		if err := r.file.SetConditionalFormat(sheetName, cells, options); err != nil {
+			log.DefaultLogger.Error("setColumnFormats: SetConditionalFormat: " + err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addConditionalFormats adds conditional formats by their ranges, in order of their ranges so
+// their priorities are the same each time.
+func (r *Report) addConditionalFormats(sheetName string, formats map[string][]excelize.ConditionalFormatOptions) error {
+	ranges := make([]string, 0, len(formats))
+	for cells := range formats {
+		ranges = append(ranges, cells)
+	}
+	sort.Strings(ranges)
+	for _, cells := range ranges {
+		if err := r.file.SetConditionalFormat(sheetName, cells, formats[cells]); err != nil {
+			log.DefaultLogger.Error("addConditionalFormats: SetConditionalFormat: " + err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
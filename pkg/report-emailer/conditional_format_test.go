@@ -0,0 +1,236 @@
+package reportEmailer
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"excel-report-email-scheduler/pkg/api"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func float(value float64) *float64 {
+	return &value
+}
+
+// ruleFormulas returns the formula and colour of each rule, in order.
+func ruleFormulas(rules []formatRule) []string {
+	formulas := []string{}
+	for _, rule := range rules {
+		formulas = append(formulas, rule.formula+" "+rule.color)
+	}
+	return formulas
+}
+
+func TestMappingRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings []api.ValueMapping
+		want     []string
+	}{
+		{
+			name: "text and numbers",
+			mappings: []api.ValueMapping{{Type: "value", Values: map[string]api.MappingResult{
+				"Out of stock": {Color: "red"},
+				"10":           {Color: "green"},
+				`Say "hi"`:     {Color: "blue"},
+			}}},
+			want: []string{
+				"AND(ISNUMBER(B5),B5=10) green",
+				"EXACT(B5,\"Out of stock\") red",
+				"EXACT(B5,\"Say \"\"hi\"\"\") blue",
+			},
+		},
+		{
+			name: "ranges",
+			mappings: []api.ValueMapping{
+				{Type: "range", From: float(0), To: float(9.5), Result: api.MappingResult{Color: "red"}},
+				{Type: "range", From: float(10), Result: api.MappingResult{Color: "green"}},
+				{Type: "range", To: float(-1), Result: api.MappingResult{Color: "blue"}},
+			},
+			want: []string{
+				"AND(ISNUMBER(B5),B5>=0,B5<=9.5) red",
+				"AND(ISNUMBER(B5),B5>=10) green",
+				"AND(ISNUMBER(B5),B5<=-1) blue",
+			},
+		},
+		{
+			name: "special values",
+			mappings: []api.ValueMapping{
+				{Type: "special", Match: "null+nan", Result: api.MappingResult{Color: "red"}},
+				{Type: "special", Match: "empty", Result: api.MappingResult{Color: "orange"}},
+				{Type: "special", Match: "true", Result: api.MappingResult{Color: "green"}},
+				{Type: "special", Match: "nan", Result: api.MappingResult{Color: "blue"}},
+			},
+			want: []string{
+				"ISBLANK(B5) red",
+				"B5=\"\" orange",
+				"B5=TRUE green",
+			},
+		},
+		{
+			name: "regular expressions are left out",
+			mappings: []api.ValueMapping{
+				{Type: "regex", Pattern: "^Zinc", Result: api.MappingResult{Color: "red"}},
+			},
+			want: []string{},
+		},
+		{
+			name: "in the order of their index",
+			mappings: []api.ValueMapping{
+				{Type: "range", From: float(0), Result: api.MappingResult{Color: "green", Index: 2}},
+				{Type: "value", Values: map[string]api.MappingResult{"0": {Color: "red", Index: 1}}},
+				{Type: "special", Match: "null", Result: api.MappingResult{Color: "blue", Index: 2}},
+			},
+			want: []string{
+				"AND(ISNUMBER(B5),B5=0) red",
+				"AND(ISNUMBER(B5),B5>=0) green",
+				"ISBLANK(B5) blue",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ruleFormulas(mappingRules("B5", test.mappings)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("mappingRules = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMappingRulesKeepText(t *testing.T) {
+	rules := mappingRules("B5", []api.ValueMapping{{Type: "value", Values: map[string]api.MappingResult{"1": {Text: "Open"}}}})
+	if len(rules) != 1 || rules[0].text != "Open" {
+		t.Errorf("mappingRules = %+v, want one rule with the text Open", rules)
+	}
+}
+
+func TestThresholdRules(t *testing.T) {
+	steps := []api.ThresholdStep{
+		{Color: "green"},
+		{Color: "orange", Value: float(50)},
+		{Color: "red", Value: float(80)},
+	}
+
+	tests := []struct {
+		name       string
+		thresholds api.Thresholds
+		values     []float64
+		want       []string
+	}{
+		{
+			name:       "absolute",
+			thresholds: api.Thresholds{Mode: "absolute", Steps: steps},
+			values:     []float64{10, 200},
+			want: []string{
+				"AND(ISNUMBER(C5),C5>=80) red",
+				"AND(ISNUMBER(C5),C5>=50) orange",
+				"ISNUMBER(C5) green",
+			},
+		},
+		{
+			name: "unusable steps are left out",
+			thresholds: api.Thresholds{Mode: "absolute", Steps: []api.ThresholdStep{
+				{Color: "green"},
+				{Color: "orange", Unusable: true},
+				{Color: "red", Value: float(80)},
+			}},
+			want: []string{
+				"AND(ISNUMBER(C5),C5>=80) red",
+				"ISNUMBER(C5) green",
+			},
+		},
+		{
+			name:       "percentage of the range of the values",
+			thresholds: api.Thresholds{Mode: "percentage", Steps: steps},
+			values:     []float64{100, 300, 200},
+			want: []string{
+				"AND(ISNUMBER(C5),C5>=260) red",
+				"AND(ISNUMBER(C5),C5>=200) orange",
+				"ISNUMBER(C5) green",
+			},
+		},
+		{
+			name:       "percentage when every value is the same",
+			thresholds: api.Thresholds{Mode: "percentage", Steps: steps},
+			values:     []float64{7, 7, 7},
+			want:       []string{"ISNUMBER(C5) green"},
+		},
+		{
+			name: "percentage steps at 0% when every value is the same",
+			thresholds: api.Thresholds{Mode: "percentage", Steps: []api.ThresholdStep{
+				{Color: "green"},
+				{Color: "orange", Value: float(0)},
+				{Color: "red", Value: float(80)},
+			}},
+			values: []float64{7, 7},
+			want: []string{
+				"AND(ISNUMBER(C5),C5>=7) orange",
+				"ISNUMBER(C5) green",
+			},
+		},
+		{
+			name:       "percentage without values",
+			thresholds: api.Thresholds{Mode: "percentage", Steps: steps},
+			want:       []string{"ISNUMBER(C5) green"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ruleFormulas(thresholdRules("C5", test.thresholds, test.values)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("thresholdRules = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTableConditionalFormats(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	format, err := file.NewConditionalStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rows 4 and on are the table's rows
+	for _, cells := range []string{"A4:C4", "A4:A5 C3:C4", "A1:A2", "A6:C6"} {
+		options := []excelize.ConditionalFormatOptions{{Type: "formula", Criteria: "MOD(ROW(),2)=0", Format: format}}
+		if err := file.SetConditionalFormat("Sheet1", cells, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &Report{file: file}
+	formats, err := r.tableConditionalFormats("Sheet1", 4, 10)
+	if err != nil {
+		t.Fatalf("tableConditionalFormats returned an error: %v", err)
+	}
+
+	got := []string{}
+	for cells, options := range formats {
+		got = append(got, cells)
+		if len(options) != 1 || options[0].Criteria != "MOD(ROW(),2)=0" {
+			t.Errorf("the formats of %s are %+v, want the template's format", cells, options)
+		}
+	}
+	sort.Strings(got)
+	if want := []string{"A4:A10 C3:C10", "A4:C10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the table's formats cover %q, want %q", got, want)
+	}
+
+	remaining, err := file.GetConditionalFormats("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := []string{}
+	for cells := range remaining {
+		kept = append(kept, cells)
+	}
+	sort.Strings(kept)
+	if want := []string{"A1:A2", "A6:C6"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("the template keeps formats on %q, want %q", kept, want)
+	}
+}
@@ -0,0 +1,164 @@
+package reportEmailer
+
+import (
+	"excel-report-email-scheduler/pkg/datasource"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const (
+	waitingForUpstream = "Waiting for upstream schedules to succeed: "
+	waitedForUpstream  = "Waited for upstream schedules to succeed: "
+)
+
+// waitForUpstream reports whether an overdue schedule has to wait for its upstream schedules to
+// succeed in the same period. While it waits, a single blocked run records the reason for its
+// report time, and it is finished once they have succeeded. Once the schedule's following report
+// time has passed it stops waiting for this one: the blocked run is finished and the schedule
+// moves on.
+func (re *ReportEmailer) waitForUpstream(schedule datasource.Schedule) (bool, error) {
+	if len(schedule.DependsOn) == 0 {
+		return false, nil
+	}
+
+	from, to, err := re.period(schedule)
+	if err != nil {
+		return false, err
+	}
+
+	waitingFor := []string{}
+	for _, upstreamID := range schedule.DependsOn {
+		succeeded, err := re.datasource.SucceededBetween(upstreamID, from, to)
+		if err != nil {
+			return false, err
+		}
+
+		if !succeeded {
+			name := upstreamID
+			if upstream, err := re.datasource.GetSchedule(upstreamID); err == nil {
+				name = upstream.Name
+			}
+			waitingFor = append(waitingFor, name)
+		}
+	}
+
+	if len(waitingFor) == 0 {
+		return false, re.unblock(schedule)
+	}
+
+	run, err := re.blockedRun(schedule)
+	if err != nil {
+		return false, err
+	}
+
+	run.Error = waitingForUpstream + strings.Join(waitingFor, ", ")
+
+	following := followingReportTime(schedule)
+	periodOver := !following.IsZero() && time.Now().After(following)
+	if periodOver {
+		run.EndTime = int(time.Now().Unix())
+		run.Error = "Upstream schedules did not succeed before the next report time: " + strings.Join(waitingFor, ", ")
+	}
+
+	if err := re.datasource.UpdateScheduleRun(*run); err != nil {
+		return false, err
+	}
+
+	if periodOver {
+		log.DefaultLogger.Info(fmt.Sprintf("Schedule '%s' gave up waiting for: %s", schedule.Name, strings.Join(waitingFor, ", ")))
+		if err := re.moveOn(schedule); err != nil {
+			return false, err
+		}
+	} else {
+		log.DefaultLogger.Info(fmt.Sprintf("Schedule '%s' is waiting for: %s", schedule.Name, strings.Join(waitingFor, ", ")))
+	}
+
+	return true, nil
+}
+
+// period returns the times between which upstream runs count towards the schedule's next report
+// time, after from and up to and including to.
+func (re *ReportEmailer) period(schedule datasource.Schedule) (int, int, error) {
+	lastDelivered, err := re.datasource.LastDeliveredReportTime(schedule.ID, schedule.NextReportTime)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	from, to := reportPeriod(schedule, lastDelivered)
+	return from, to, nil
+}
+
+// reportPeriod returns the period of the schedule's next report time. The period takes in every
+// time closer to that report time than to the ones before and after it, so an upstream schedule
+// counts whether it runs a little before or a little after the schedule. It never reaches back
+// to lastDelivered, the last report time the schedule delivered, and is open ended when there is
+// no following report time.
+func reportPeriod(schedule datasource.Schedule, lastDelivered int) (int, int) {
+	reportTime := schedule.NextReportTime
+
+	from, to := 0, math.MaxInt
+	if following := followingReportTime(schedule); !following.IsZero() {
+		half := (int(following.Unix()) - reportTime) / 2
+		from, to = reportTime-half, reportTime+half
+	}
+
+	if lastDelivered > from {
+		from = lastDelivered
+	}
+
+	return from, to
+}
+
+// followingReportTime returns the report time after the schedule's next one, or the zero time
+// when the schedule ends before it.
+func followingReportTime(schedule datasource.Schedule) time.Time {
+	following := schedule.NextReportTimeAfter(time.Unix(int64(schedule.NextReportTime)+1, 0))
+	if schedule.EndDate > 0 && following.Unix() > int64(schedule.EndDate) {
+		return time.Time{}
+	}
+	return following
+}
+
+// blockedRun returns the blocked run recorded for the schedule's next report time, creating it
+// the first time the schedule is held back.
+func (re *ReportEmailer) blockedRun(schedule datasource.Schedule) (*datasource.ScheduleRun, error) {
+	runs, err := re.datasource.ScheduledRuns(schedule.ID, schedule.NextReportTime)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, run := range runs {
+		if run.Status == datasource.ScheduleRunStatusBlocked {
+			return &run, nil
+		}
+	}
+
+	return re.datasource.CreateScheduleRun(datasource.ScheduleRun{ScheduleID: schedule.ID, Status: datasource.ScheduleRunStatusBlocked, ScheduledTime: schedule.NextReportTime})
+}
+
+// unblock finishes the blocked run recorded for the schedule's next report time, if there is
+// one, now that its upstream schedules have succeeded.
+func (re *ReportEmailer) unblock(schedule datasource.Schedule) error {
+	runs, err := re.datasource.ScheduledRuns(schedule.ID, schedule.NextReportTime)
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		if run.Status != datasource.ScheduleRunStatusBlocked || run.EndTime != 0 {
+			continue
+		}
+
+		run.EndTime = int(time.Now().Unix())
+		run.Error = waitedForUpstream + strings.TrimPrefix(run.Error, waitingForUpstream)
+		if err := re.datasource.UpdateScheduleRun(run); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
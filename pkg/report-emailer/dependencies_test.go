@@ -0,0 +1,101 @@
+package reportEmailer
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"excel-report-email-scheduler/pkg/datasource"
+)
+
+// unixAt returns the unix time of a UTC time formatted as 2006-01-02 15:04.
+func unixAt(t *testing.T, value string) int {
+	t.Helper()
+	parsed, err := time.Parse("2006-01-02 15:04", value)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", value, err)
+	}
+	return int(parsed.Unix())
+}
+
+func TestReportPeriod(t *testing.T) {
+	daily := datasource.Schedule{Interval: 0, Time: "08:00", TimeZone: "UTC"}
+	weekdays := datasource.Schedule{CronExpression: "0 9 * * 1-5", TimeZone: "UTC"}
+	endingDaily := daily
+	endingDaily.EndDate = unixAt(t, "2024-05-15 12:00")
+
+	tests := []struct {
+		name          string
+		schedule      datasource.Schedule
+		next          string
+		lastDelivered string
+		from          string
+		to            string
+	}{
+		{
+			name:     "halfway to the report times either side",
+			schedule: daily,
+			next:     "2024-05-15 08:00",
+			from:     "2024-05-14 20:00",
+			to:       "2024-05-15 20:00",
+		},
+		{
+			name:          "a delivered report time before the period",
+			schedule:      daily,
+			next:          "2024-05-15 08:00",
+			lastDelivered: "2024-05-14 08:00",
+			from:          "2024-05-14 20:00",
+			to:            "2024-05-15 20:00",
+		},
+		{
+			name:          "a delivered report time within the period",
+			schedule:      daily,
+			next:          "2024-05-15 08:00",
+			lastDelivered: "2024-05-15 02:00",
+			from:          "2024-05-15 02:00",
+			to:            "2024-05-15 20:00",
+		},
+		{
+			name:     "cron over a weekend",
+			schedule: weekdays,
+			next:     "2024-05-17 09:00",
+			from:     "2024-05-15 21:00",
+			to:       "2024-05-18 21:00",
+		},
+		{
+			name:     "the last report time before the end date",
+			schedule: endingDaily,
+			next:     "2024-05-15 08:00",
+		},
+		{
+			name:          "the last report time after a delivered one",
+			schedule:      endingDaily,
+			next:          "2024-05-15 08:00",
+			lastDelivered: "2024-05-14 08:00",
+			from:          "2024-05-14 08:00",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule := test.schedule
+			schedule.NextReportTime = unixAt(t, test.next)
+			lastDelivered := 0
+			if test.lastDelivered != "" {
+				lastDelivered = unixAt(t, test.lastDelivered)
+			}
+			wantFrom, wantTo := 0, math.MaxInt
+			if test.from != "" {
+				wantFrom = unixAt(t, test.from)
+			}
+			if test.to != "" {
+				wantTo = unixAt(t, test.to)
+			}
+
+			from, to := reportPeriod(schedule, lastDelivered)
+			if from != wantFrom || to != wantTo {
+				t.Errorf("reportPeriod = %d to %d, want %d to %d", from, to, wantFrom, wantTo)
+			}
+		})
+	}
+}
@@ -33,10 +33,15 @@ func (e *Emailer) CreateAndSend(attachmentPath, email, subject, body string) err
 	return nil
 }
 
-func (e *Emailer) BulkCreateAndSend(attachmentPath string, emails []string, subject string, body string) {
+// BulkCreateAndSend sends the attachment to each address and returns the addresses it was sent to successfully.
+func (e *Emailer) BulkCreateAndSend(attachmentPath string, emails []string, subject string, body string) []string {
+	sent := []string{}
 	for _, email := range emails {
 		if err := e.CreateAndSend(attachmentPath, email, subject, body); err != nil {
 			log.DefaultLogger.Error("BulkCreateAndSend: Could not send to: " + email)
+		} else {
+			sent = append(sent, email)
 		}
 	}
+	return sent
 }
@@ -4,21 +4,19 @@ import (
 	"errors"
 	"excel-report-email-scheduler/pkg/api"
 	"excel-report-email-scheduler/pkg/auth"
+	"excel-report-email-scheduler/pkg/datasource"
 	"fmt"
 	_ "image/png"
-	"math"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/360EntSecGroup-Skylar/excelize"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 )
 
-var CELL_REF_REG = regexp.MustCompile("([A-Za-z]+)|([0-9]+)")
-
 func intToCol(i int) string {
 	i += 1
 	result := ""
@@ -36,74 +34,7 @@ func intToCol(i int) string {
 }
 
 func NewReport(id string, name string, templatePath string) *Report {
-	return &Report{id: id, name: name, templatePath: templatePath}
-}
-
-func (r *Report) openTemplate() error {
-	f, err := excelize.OpenFile(r.templatePath)
-	if err != nil {
-		log.DefaultLogger.Error("Could not open template: ", err.Error())
-		return err
-	}
-
-	r.file = f
-
-	return nil
-}
-
-func (r *Report) placeholderCellRef(sheetName string, placeholder string) ([]string, error) {
-	refs := r.file.SearchSheet(sheetName, placeholder)
-
-	if len(refs) == 0 {
-		err := errors.New("Could not find cell reference for: " + sheetName + " - " + placeholder)
-		log.DefaultLogger.Error("placeholderCellRef", err.Error())
-		return nil, err
-	}
-
-	return refs, nil
-}
-
-func (r *Report) placeholderRowRef(sheetName string, placeholder string) (int, error) {
-	refs := r.file.SearchSheet(sheetName, placeholder)
-
-	if len(refs) == 0 {
-		err := errors.New("Could not find cell reference for: " + sheetName + " - " + placeholder)
-		log.DefaultLogger.Error("placeholderRowRef", err.Error())
-		return 0, err
-	}
-
-	split := CELL_REF_REG.FindAllString(refs[0], 2)
-	idx, err := strconv.Atoi(split[1])
-	if err != nil {
-		log.DefaultLogger.Error("placeholderRowRef: Atoi: " + err.Error())
-		return 0, err
-	}
-
-	return idx, nil
-}
-
-func (r *Report) writeTitle(sheetName string) error {
-	refs, err := r.placeholderCellRef(sheetName, "{{title}}")
-	if err != nil {
-		log.DefaultLogger.Error("writeTitle: placeholderCellRef: " + err.Error())
-		return err
-	}
-
-	r.file.SetCellValue(sheetName, refs[0], sheetName)
-
-	return nil
-}
-
-func (r *Report) writeDate(sheetName string) error {
-	refs, err := r.placeholderCellRef(sheetName, "{{date}}")
-	if err != nil {
-		log.DefaultLogger.Error("writeDate: ", err.Error())
-		return err
-	}
-
-	r.file.SetCellValue(sheetName, refs[0], time.Now().Format("Mon Jan 2 15:04:05"))
-
-	return nil
+	return &Report{id: id, name: name, templatePath: templatePath, location: time.Local, format: xlsxFormat{}}
 }
 
 func toDateString(value interface{}) (string, bool) {
@@ -137,214 +68,94 @@ func toDateString(value interface{}) (string, bool) {
 	return date, false
 }
 
-func (r *Report) writeCell(sheetName string, cellRef string, value interface{}) {
-	if date, ok := toDateString(value); ok {
-		value = date
-		if style, err := r.file.NewStyle(`{"number_format": 14,  "alignment": { "horizontal": "right", "vertical": "center" }}`); err == nil {
-			r.file.SetCellStyle(sheetName, cellRef, cellRef, style)
-		}
-	}
-	if _, ok := value.(string); ok {
-		r.file.SetCellValue(sheetName, cellRef, value)
-	} else if boolean, ok := value.(bool); ok {
-		r.file.SetCellBool(sheetName, cellRef, boolean)
-	} else {
-		if style, err := r.file.NewStyle(`{"number_format": 2, "alignment": { "vertical": "center" }}`); err == nil {
-			r.file.SetCellStyle(sheetName, cellRef, cellRef, style)
-		}
-		r.file.SetCellValue(sheetName, cellRef, value)
-	}
-}
-
 func (r *Report) SetSheets(panels []api.TablePanel) {
 	r.sheets = panels
 }
 
-func (r *Report) writeHeaders(sheetName string, columns []api.Column) error {
-	idx, err := r.placeholderRowRef(sheetName, "{{headers}}")
-
-	style := r.file.GetCellStyle(sheetName, r.createCellRef(0, idx))
-
-	if err != nil {
-		log.DefaultLogger.Error("writeHeaders: ", err.Error())
-		return err
-	}
-
-	if len(columns) > 0 {
-		for i, column := range columns {
-			cellRef := r.createCellRef(i, idx)
-			r.file.SetCellStyle(sheetName, cellRef, cellRef, style)
-			r.writeCell(sheetName, cellRef, column.Text)
-		}
-	} else {
-		cellRef := r.createCellRef(0, idx)
-		r.writeCell(sheetName, cellRef, "")
-	}
-
-	return nil
+func (r *Report) SetLocation(location *time.Location) {
+	r.location = location
 }
 
-func Min(x, y int) int {
-	if x > y {
-		return y
-	}
-	return x
+func (r *Report) SetFormat(format ReportFormat) {
+	r.format = format
 }
 
-func (r *Report) createDuplicateTableRows(sheetName string, numberToDuplicate int) (int, error) {
-	idx, err := r.placeholderRowRef(sheetName, "{{rows}}")
-	if err != nil {
-		log.DefaultLogger.Error("createDuplicateTableRows: " + err.Error())
-		return 0, err
-	}
-
-	duplicateNumber := Min(numberToDuplicate, 500)
-	i := 0
-	for i < duplicateNumber {
-		r.file.DuplicateRow(sheetName, idx)
-		i += 1
-	}
-
-	return idx, nil
+func (r *Report) SetSummarySheet(summarySheet bool) {
+	r.summarySheet = summarySheet
 }
 
-func (r *Report) createCellRef(columnNumber int, rowNumber int) string {
-	return intToCol(columnNumber) + strconv.Itoa(rowNumber)
+func (r *Report) SetLayout(layout string) {
+	r.layout = layout
 }
 
-func (r *Report) writeRows(sheetName string, rows [][]interface{}) error {
-	idx, err := r.placeholderRowRef(sheetName, "{{rows}}")
-	if err != nil {
-		log.DefaultLogger.Error("writeRows: placeholderRowRef: " + err.Error())
-		return err
-	}
-
-	style := r.file.GetCellStyle(sheetName, r.createCellRef(0, idx))
-	if len(rows) > 0 {
-		for _, row := range rows {
-			for j, value := range row {
-				cellRef := r.createCellRef(j, idx)
-				r.file.SetCellStyle(sheetName, cellRef, cellRef, style)
-				if value == nil {
-					r.writeCell(sheetName, cellRef, "\n")
-				} else {
-					r.writeCell(sheetName, cellRef, value)
-				}
-
-			}
-			idx += 1
+// RowCounts returns the number of rows fetched for each sheet of the report, along with
+// the error for any sheet whose data could not be fetched.
+func (r *Report) RowCounts() []datasource.PanelRowCount {
+	counts := []datasource.PanelRowCount{}
+	for i, sheet := range r.sheets {
+		count := datasource.PanelRowCount{PanelID: sheet.ID, Title: sheet.Title, Rows: len(sheet.Rows)}
+		if err, ok := r.sheetErrors[i]; ok {
+			count.Rows = 0
+			count.Error = err.Error()
 		}
-	} else {
-		cellRef := r.createCellRef(0, idx)
-		r.writeCell(sheetName, cellRef, "No data")
+		counts = append(counts, count)
 	}
-
-	return nil
+	return counts
 }
 
-func (r *Report) setColumnWidths(sheetName string, columns []api.Column, rows [][]interface{}) error {
-
-	headerFontCorrectionFactor := 1.4
-	maximumContentLengths := make(map[int]float64)
-
-	for columnNumber, column := range columns {
-		maximumContentLengths[columnNumber] = headerFontCorrectionFactor * (2 + float64(len(column.Text)))
-	}
-
-	if len(rows) == 0 {
-		return nil
-	}
-
-	for _, row := range rows {
-		for i, value := range row {
-			if value != nil {
-				if timestamp, ok := value.(float64); ok {
-					// adding 3 to the length, as we are formatting with 2 decimal places
-					maximumContentLengths[i] = math.Max(maximumContentLengths[i], float64(3+len(strconv.FormatFloat(timestamp, 'f', -1, 64))))
-
-					// if a date, then reformat as the appropriate date string
-					if date, ok := toDateString(value); ok {
-						value = date
-					}
-				}
-
-				if _, ok := value.(string); ok {
-					maximumContentLengths[i] = math.Max(maximumContentLengths[i], float64(len(value.(string))))
-				}
-			}
-		}
-	}
-
-	for columnNumber, _ := range rows {
-		r.file.SetColWidth(sheetName, intToCol(columnNumber), intToCol(columnNumber), 1.5+maximumContentLengths[columnNumber])
-	}
+// FailedSheets returns the number of sheets whose data could not be fetched.
+func (r *Report) FailedSheets() int {
+	return len(r.sheetErrors)
+}
 
-	return nil
+func (r *Report) createCellRef(columnNumber int, rowNumber int) string {
+	return intToCol(columnNumber) + strconv.Itoa(rowNumber)
 }
 
+// Write fetches the data of each sheet and writes the report in its format. A panel whose
+// data cannot be fetched still gets its sheet, explaining what went wrong, so the rest of the
+// report can be sent.
 func (r *Report) Write(auth auth.AuthConfig) error {
 	log.DefaultLogger.Info(fmt.Sprintf("Starting to create report %s...", r.id))
-	if r.file == nil {
-		if err := r.openTemplate(); err != nil {
-			return err
-		}
-	}
-
-	for _, s := range r.sheets {
-		log.DefaultLogger.Info(fmt.Sprintf("Creating new sheet %s", s.Title))
-		sIdx := r.file.NewSheet(s.Title)
-
-		if err := r.file.CopySheet(1, sIdx); err != nil {
-			log.DefaultLogger.Error("Write: copySheet: " + err.Error())
-			return err
-		}
+	r.generatedAt = time.Now()
+	r.grafanaURL = strings.TrimSuffix(auth.URL, "/")
 
+	r.sheetErrors = make(map[int]error)
+	for i := range r.sheets {
+		s := &r.sheets[i]
 		if err := s.GetData(auth); err != nil {
-			log.DefaultLogger.Error("Write: GetData: " + err.Error())
-			return err
-		}
-
-		if err := r.writeTitle(s.Title); err != nil {
-			log.DefaultLogger.Error("Write: writeTitle: " + err.Error())
-			return err
-		}
-
-		if err := r.writeDate(s.Title); err != nil {
-			log.DefaultLogger.Error("Write: writeDate: " + err.Error())
-			return err
-		}
-
-		if err := r.writeHeaders(s.Title, s.Columns); err != nil {
-			log.DefaultLogger.Error("Write: writeHeaders: " + err.Error())
-			return err
-		}
-
-		if err := r.writeRows(s.Title, s.Rows); err != nil {
-			log.DefaultLogger.Error("Write: writeRows: " + err.Error())
-			return err
-		}
-
-		if err := r.setColumnWidths(s.Title, s.Columns, s.Rows); err != nil {
-			log.DefaultLogger.Error("Write: setColumnWidths: " + err.Error())
-			return err
+			log.DefaultLogger.Error(fmt.Sprintf("Write: GetData: %s: %s", s.Title, err.Error()))
+			r.sheetErrors[i] = err
+			s.SetColumns([]api.Column{})
+			s.SetRows([][]interface{}{{"Could not load data: " + err.Error()}})
 		}
 	}
 
-	r.file.DeleteSheet("templateSheet")
-
 	log.DefaultLogger.Info("Saving report...")
 
-	savePath := GetFilePath(r.name)
-	if err := r.file.SaveAs(savePath); err != nil {
+	r.path = GetFilePathWithExtension(r.name, r.format.Extension(r))
+	if err := r.format.Write(r, r.path); err != nil {
 		log.DefaultLogger.Error("Write: ", err.Error())
+		return err
 	}
 
-	log.DefaultLogger.Info(fmt.Sprintf("Report finished! %s (%s) :tada", r.id, savePath))
+	log.DefaultLogger.Info(fmt.Sprintf("Report finished! %s (%s) :tada", r.id, r.path))
 
 	return nil
 }
 
-func (r *Reporter) ExportPanel(authConfig *auth.AuthConfig, datasourceID int, dashboardID string, panelID int, query string, title string) (string, error) {
+// Path returns the file the report was written to.
+func (r *Report) Path() string {
+	return r.path
+}
+
+// ExportPanel writes a single panel to a file in the given format and returns the file's name.
+func (r *Reporter) ExportPanel(authConfig *auth.AuthConfig, datasourceID int, dashboardID string, panelID int, query string, title string, format string) (string, error) {
+	reportFormat, err := NewReportFormat(format)
+	if err != nil {
+		log.DefaultLogger.Error("Reporter.ExportPanel: NewReportFormat: " + err.Error())
+		return "", err
+	}
 
 	dashboard, err := api.NewDashboard(authConfig, dashboardID, "", "", datasourceID)
 	if err != nil {
@@ -365,6 +176,7 @@ func (r *Reporter) ExportPanel(authConfig *auth.AuthConfig, datasourceID int, da
 	reportSheetPanels := []api.TablePanel{*panel}
 	report := r.CreateNewReport(strconv.Itoa(panelID), panel.Title)
 	report.SetSheets(reportSheetPanels)
+	report.SetFormat(reportFormat)
 
 	err = report.Write(*authConfig)
 	if err != nil {
@@ -372,10 +184,18 @@ func (r *Reporter) ExportPanel(authConfig *auth.AuthConfig, datasourceID int, da
 		return "", err
 	}
 
-	return panel.Title + ".xlsx", nil
+	if report.FailedSheets() > 0 {
+		return "", errors.New(report.RowCounts()[0].Error)
+	}
+
+	return filepath.Base(report.Path()), nil
 }
 
 func GetFilePath(fileName string) string {
+	return GetFilePathWithExtension(fileName, datasource.ReportFormatXlsx)
+}
+
+func GetFilePathWithExtension(fileName string, extension string) string {
 
 	var runningOS string
 	var filePath string
@@ -383,9 +203,9 @@ func GetFilePath(fileName string) string {
 	runningOS = runtime.GOOS
 
 	if runningOS == "windows" {
-		filePath = filepath.Join("..", "data", fileName+".xlsx")
+		filePath = filepath.Join("..", "data", fileName+"."+extension)
 	} else {
-		filePath = filepath.Join("/var/lib/grafana/plugins", "data", fileName+".xlsx")
+		filePath = filepath.Join("/var/lib/grafana/plugins", "data", fileName+"."+extension)
 	}
 
 	log.DefaultLogger.Debug("mSupply App: ReportFilePath=" + filePath)
@@ -0,0 +1,520 @@
+package reportEmailer
+
+import (
+	"errors"
+	"excel-report-email-scheduler/pkg/api"
+	"excel-report-email-scheduler/pkg/datasource"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	// excel's built in number formats for 0.00 and for dates
+	numberFormatDecimal = 2
+	numberFormatDate    = 14
+	maxColumnWidth      = 100
+)
+
+// valueKind is the kind of value in a cell of a table, which decides how the cell is styled.
+type valueKind int
+
+const (
+	valueKindText valueKind = iota
+	valueKindNumber
+	valueKindDate
+)
+
+type styleKey struct {
+	base   int
+	kind   valueKind
+	numFmt string
+}
+
+// templateRow is a row of the template sheet, kept while the sheet is written again as a stream.
+type templateRow struct {
+	cells  []interface{}
+	height float64
+}
+
+func (r *Report) openTemplate() error {
+	f, err := excelize.OpenFile(r.templatePath)
+	if err != nil {
+		log.DefaultLogger.Error("Could not open template: ", err.Error())
+		return err
+	}
+
+	r.file = f
+	r.styles = make(map[styleKey]int)
+
+	return nil
+}
+
+// writeXlsx fills a copy of the template sheet for each sheet of the report, or a single copy
+// with the sheets stacked one below another, and saves the workbook to path.
+func (r *Report) writeXlsx(path string) error {
+	if r.file == nil {
+		if err := r.openTemplate(); err != nil {
+			return err
+		}
+	}
+	defer r.file.Close()
+
+	templateIndex, err := r.file.GetSheetIndex(TEMPLATE_SHEET)
+	if err != nil || templateIndex < 0 {
+		return errors.New("the template has no sheet named " + TEMPLATE_SHEET)
+	}
+
+	names := r.sheetNames()
+	links := make([]string, len(names))
+	for i, name := range names {
+		links[i] = sheetCellRef(name, "A1")
+	}
+
+	stacked := r.layout == datasource.LayoutStacked
+	var layout stackedLayout
+	if stacked {
+		if layout, err = r.planStackedSheet(); err != nil {
+			log.DefaultLogger.Error("writeXlsx: planStackedSheet: " + err.Error())
+			return err
+		}
+		links = layout.links()
+	}
+
+	if r.summarySheet {
+		if err := r.writeSummarySheet(links); err != nil {
+			log.DefaultLogger.Error("writeXlsx: writeSummarySheet: " + err.Error())
+			return err
+		}
+	}
+
+	if stacked {
+		err = r.writeStackedSheet(templateIndex, layout)
+	} else {
+		err = r.writeSheets(templateIndex, names)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := r.file.DeleteSheet(TEMPLATE_SHEET); err != nil {
+		return err
+	}
+	r.file.SetActiveSheet(0)
+
+	return r.file.SaveAs(path)
+}
+
+// writeSheets fills a copy of the template sheet for each sheet of the report.
+func (r *Report) writeSheets(templateIndex int, names []string) error {
+	for i, s := range r.sheets {
+		name := names[i]
+		log.DefaultLogger.Info(fmt.Sprintf("Creating new sheet %s", name))
+		sIdx, err := r.file.NewSheet(name)
+		if err != nil {
+			log.DefaultLogger.Error("writeSheets: NewSheet: " + err.Error())
+			return err
+		}
+
+		if err := r.file.CopySheet(templateIndex, sIdx); err != nil {
+			log.DefaultLogger.Error("writeSheets: copySheet: " + err.Error())
+			return err
+		}
+
+		if err := r.fillPlaceholders(name, s); err != nil {
+			log.DefaultLogger.Error("writeSheets: fillPlaceholders: " + err.Error())
+			return err
+		}
+
+		if err := r.streamTable(name, s); err != nil {
+			log.DefaultLogger.Error("writeSheets: streamTable: " + err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Report) placeholderRowRef(sheetName string, placeholder string) (int, error) {
+	refs, err := r.file.SearchSheet(sheetName, placeholder)
+	if err == nil && len(refs) == 0 {
+		err = errors.New("Could not find cell reference for: " + sheetName + " - " + placeholder)
+	}
+	if err != nil {
+		log.DefaultLogger.Error("placeholderRowRef", err.Error())
+		return 0, err
+	}
+
+	_, row, err := excelize.CellNameToCoordinates(refs[0])
+	if err != nil {
+		log.DefaultLogger.Error("placeholderRowRef: CellNameToCoordinates: " + err.Error())
+		return 0, err
+	}
+
+	return row, nil
+}
+
+// streamTable writes the sheet again with a stream writer, so that a table's cells are not
+// each held in the workbook as it is built. The table's rows themselves are already in memory,
+// as Grafana returns a query's result whole. The template's rows are kept, the {{headers}} row is replaced by
+// the column headers and the {{rows}} row by the table's rows, moving any rows below it down.
+// Each column of the table is styled like its cell in the {{rows}} row of the template. A chart
+// panel's table is drawn by a native chart beside it.
+func (r *Report) streamTable(sheetName string, sheet api.TablePanel) error {
+	columns, rows := sheet.Columns, sheet.Rows
+	if sheet.IsChart() {
+		columns = chartColumns(columns)
+	}
+
+	headersRow, err := r.placeholderRowRef(sheetName, "{{headers}}")
+	if err != nil {
+		return err
+	}
+	rowsRow, err := r.placeholderRowRef(sheetName, "{{rows}}")
+	if err != nil {
+		return err
+	}
+
+	template, err := r.readTemplateRows(sheetName)
+	if err != nil {
+		return err
+	}
+	merges, err := r.file.GetMergeCells(sheetName)
+	if err != nil {
+		return err
+	}
+
+	headerStyle, rowStyles, err := r.tableStyles(sheetName, columns, headersRow, rowsRow)
+	if err != nil {
+		return err
+	}
+
+	sw, err := r.file.NewStreamWriter(sheetName)
+	if err != nil {
+		return err
+	}
+	if err := r.setColumnWidths(sw, sheetName, columnWidths(columns, rows), template); err != nil {
+		return err
+	}
+
+	// rows of the template below the table move down to make room for it
+	added := len(rows) - 1
+	if added < 0 {
+		added = 0
+	}
+	moved := func(row int) int {
+		if row > rowsRow {
+			return row + added
+		}
+		return row
+	}
+
+	for rowNumber := 1; rowNumber <= len(template) || rowNumber <= rowsRow || rowNumber <= headersRow; rowNumber++ {
+		var err error
+		switch rowNumber {
+		case headersRow:
+			err = r.streamHeaders(sw, moved(rowNumber), columns, headerStyle)
+		case rowsRow:
+			err = r.streamRows(sw, rowNumber, columns, rows, rowStyles)
+		default:
+			err = r.streamTemplateRow(sw, template, rowNumber, moved(rowNumber), sheet)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, merge := range merges {
+		_, start, _ := excelize.CellNameToCoordinates(merge.GetStartAxis())
+		_, end, _ := excelize.CellNameToCoordinates(merge.GetEndAxis())
+		if (start <= headersRow && headersRow <= end) || (start <= rowsRow && rowsRow <= end) {
+			continue
+		}
+		startColumn, _, _ := excelize.CellNameToCoordinates(merge.GetStartAxis())
+		endColumn, _, _ := excelize.CellNameToCoordinates(merge.GetEndAxis())
+		if err := sw.MergeCell(r.createCellRef(startColumn-1, moved(start)), r.createCellRef(endColumn-1, moved(end))); err != nil {
+			return err
+		}
+	}
+
+	// the worksheet's conditional formats and charts are written when the stream is flushed
+	if err := r.setConditionalFormats(sheetName, columns, rows, rowsRow); err != nil {
+		return err
+	}
+	if sheet.IsChart() {
+		if err := r.addChart(sheetName, sheet, columns, headersRow, rowsRow); err != nil {
+			log.DefaultLogger.Error("streamTable: addChart: " + err.Error())
+			return err
+		}
+	}
+
+	return sw.Flush()
+}
+
+// tableStyles returns the style of the template's {{headers}} cell, which each header of a
+// table takes, and the style of each column of the table's rows in the {{rows}} row.
+func (r *Report) tableStyles(sheetName string, columns []api.Column, headersRow int, rowsRow int) (int, []int, error) {
+	headerStyle, err := r.file.GetCellStyle(sheetName, r.createCellRef(0, headersRow))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rowStyles := make([]int, len(columns))
+	for i := range rowStyles {
+		rowStyles[i], err = r.file.GetCellStyle(sheetName, r.createCellRef(i, rowsRow))
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return headerStyle, rowStyles, nil
+}
+
+// setColumnWidths sets the widths of the columns of a table. The stream replaces the
+// template's column widths, so those beside the table are set again.
+func (r *Report) setColumnWidths(sw *excelize.StreamWriter, sheetName string, widths []float64, template []templateRow) error {
+	for _, row := range template {
+		for i := len(widths); i < len(row.cells); i++ {
+			width, err := r.file.GetColWidth(sheetName, intToCol(i))
+			if err != nil {
+				return err
+			}
+			widths = append(widths, width)
+		}
+	}
+
+	for i, width := range widths {
+		if err := sw.SetColWidth(i+1, i+1, width); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// templateCell reads a cell of a sheet to be written again as a stream, keeping its style,
+// its formula, and its value as a number or boolean where it is one.
+func (r *Report) templateCell(sheetName string, cellRef string, value string) (excelize.Cell, error) {
+	style, err := r.file.GetCellStyle(sheetName, cellRef)
+	if err != nil {
+		return excelize.Cell{}, err
+	}
+	cell := excelize.Cell{StyleID: style, Value: value}
+
+	formula, err := r.file.GetCellFormula(sheetName, cellRef)
+	if err != nil {
+		return cell, err
+	}
+	if formula != "" {
+		cell.Formula = strings.TrimPrefix(formula, "=")
+		cell.Value = nil
+		return cell, nil
+	}
+
+	cellType, err := r.file.GetCellType(sheetName, cellRef)
+	if err != nil {
+		return cell, err
+	}
+	switch cellType {
+	case excelize.CellTypeUnset, excelize.CellTypeNumber:
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			cell.Value = number
+		}
+	case excelize.CellTypeBool:
+		cell.Value = value == "1"
+	}
+
+	return cell, nil
+}
+
+// readTemplateRows reads the cells and heights of the rows of a sheet.
+func (r *Report) readTemplateRows(sheetName string) ([]templateRow, error) {
+	values, err := r.file.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]templateRow, len(values))
+	for i, rowValues := range values {
+		height, err := r.file.GetRowHeight(sheetName, i+1)
+		if err != nil {
+			return nil, err
+		}
+		rows[i].height = height
+
+		for j, value := range rowValues {
+			cell, err := r.templateCell(sheetName, r.createCellRef(j, i+1), value)
+			if err != nil {
+				return nil, err
+			}
+			rows[i].cells = append(rows[i].cells, cell)
+		}
+	}
+
+	return rows, nil
+}
+
+// streamTemplateRow writes a row of the template, as read by readTemplateRows, to a row of the
+// stream, with the placeholders left in its text filled for a panel.
+func (r *Report) streamTemplateRow(sw *excelize.StreamWriter, template []templateRow, row int, rowNumber int, panel api.TablePanel) error {
+	if row > len(template) || len(template[row-1].cells) == 0 {
+		return nil
+	}
+
+	cells := make([]interface{}, len(template[row-1].cells))
+	for i, value := range template[row-1].cells {
+		cell := value.(excelize.Cell)
+		if text, ok := cell.Value.(string); ok {
+			if filled, ok := r.fillText(text, panel); ok {
+				cell.Value = filled
+			}
+		}
+		cells[i] = cell
+	}
+
+	return sw.SetRow(r.createCellRef(0, rowNumber), cells, excelize.RowOpts{Height: template[row-1].height})
+}
+
+func (r *Report) streamHeaders(sw *excelize.StreamWriter, rowNumber int, columns []api.Column, style int) error {
+	cells := []interface{}{excelize.Cell{StyleID: style, Value: ""}}
+	if len(columns) > 0 {
+		cells = make([]interface{}, len(columns))
+		for i, column := range columns {
+			cells[i] = excelize.Cell{StyleID: style, Value: column.Text}
+		}
+	}
+
+	return sw.SetRow(r.createCellRef(0, rowNumber), cells)
+}
+
+func (r *Report) streamRows(sw *excelize.StreamWriter, rowNumber int, columns []api.Column, rows [][]interface{}, columnStyles []int) error {
+	if len(rows) == 0 {
+		return sw.SetRow(r.createCellRef(0, rowNumber), []interface{}{"No data"})
+	}
+
+	formats := make([]columnFormat, len(columns))
+	for i, column := range columns {
+		formats[i] = newColumnFormat(column)
+	}
+
+	cells := []interface{}{}
+	for _, row := range rows {
+		cells = cells[:0]
+		for j, value := range row {
+			base := 0
+			if j < len(columnStyles) {
+				base = columnStyles[j]
+			}
+			format := columnFormat{guessDates: true}
+			if j < len(formats) {
+				format = formats[j]
+			}
+
+			kind := valueKindText
+			if t, ok := excelTime(value, r.location); ok && format.isTime {
+				value, kind = t, valueKindDate
+			} else if date, ok := toDateString(value); ok && format.guessDates {
+				value, kind = date, valueKindDate
+			} else if _, ok := value.(float64); ok {
+				kind = valueKindNumber
+			}
+
+			style, err := r.valueStyle(base, kind, format.numFmt)
+			if err != nil {
+				return err
+			}
+			cells = append(cells, excelize.Cell{StyleID: style, Value: value})
+		}
+
+		if err := sw.SetRow(r.createCellRef(0, rowNumber), cells); err != nil {
+			return err
+		}
+		rowNumber++
+	}
+
+	return nil
+}
+
+// valueStyle returns a style for a kind of value, based on a style of the template and given
+// the column's number format, if it has one. Styles are only created once per report.
+func (r *Report) valueStyle(base int, kind valueKind, numFmt string) (int, error) {
+	if kind == valueKindText {
+		return base, nil
+	}
+
+	key := styleKey{base: base, kind: kind, numFmt: numFmt}
+	if style, ok := r.styles[key]; ok {
+		return style, nil
+	}
+
+	definition, err := r.file.GetStyle(base)
+	if err != nil {
+		return 0, err
+	}
+
+	style := *definition
+	alignment := excelize.Alignment{Vertical: "center"}
+	if definition.Alignment != nil {
+		alignment = *definition.Alignment
+		alignment.Vertical = "center"
+	}
+	switch kind {
+	case valueKindNumber:
+		style.NumFmt = numberFormatDecimal
+	case valueKindDate:
+		style.NumFmt = numberFormatDate
+		alignment.Horizontal = "right"
+	}
+	if numFmt != "" {
+		style.NumFmt = 0
+		style.CustomNumFmt = &numFmt
+	}
+	style.Alignment = &alignment
+
+	id, err := r.file.NewStyle(&style)
+	if err != nil {
+		return 0, err
+	}
+
+	r.styles[key] = id
+	return id, nil
+}
+
+// columnWidths sizes each column of a table to fit its header and its longest value.
+func columnWidths(columns []api.Column, rows [][]interface{}) []float64 {
+	headerFontCorrectionFactor := 1.4
+
+	widths := make([]float64, len(columns))
+	for columnNumber, column := range columns {
+		widths[columnNumber] = headerFontCorrectionFactor * (2 + float64(len(column.Text)))
+	}
+
+	for _, row := range rows {
+		for i, value := range row {
+			if i >= len(widths) || value == nil {
+				continue
+			}
+
+			length := 0
+			if date, ok := toDateString(value); ok {
+				length = len(date)
+			} else if number, ok := value.(float64); ok {
+				// adding 3 to the length, as we are formatting with 2 decimal places
+				length = 3 + len(strconv.FormatFloat(number, 'f', -1, 64))
+			} else if text, ok := value.(string); ok {
+				length = len(strings.TrimSpace(text))
+			}
+			widths[i] = math.Max(widths[i], float64(length))
+		}
+	}
+
+	for i := range widths {
+		widths[i] = math.Min(1.5+widths[i], maxColumnWidth)
+	}
+
+	return widths
+}
@@ -0,0 +1,78 @@
+package reportEmailer
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"excel-report-email-scheduler/pkg/api"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestStreamTableMovesTemplateRowsDown(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+	if err := file.SetSheetName("Sheet1", TEMPLATE_SHEET); err != nil {
+		t.Fatal(err)
+	}
+
+	for cell, value := range map[string]interface{}{
+		"A1": "{{title}}",
+		"A3": "{{headers}}",
+		"A4": "{{rows}}",
+		"A6": "Total",
+		"C6": 42,
+		"A7": "Prepared for {{reportGroup}}",
+	} {
+		if err := file.SetCellValue(TEMPLATE_SHEET, cell, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, merge := range [][2]string{{"A1", "C1"}, {"A6", "B6"}, {"A7", "C8"}} {
+		if err := file.MergeCell(TEMPLATE_SHEET, merge[0], merge[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &Report{file: file, location: time.UTC, styles: make(map[styleKey]int), details: ReportDetails{ReportGroupName: "Stores"}}
+	sheet := api.TablePanel{
+		Title:   "Stock",
+		Columns: []api.Column{{Text: "Item"}, {Text: "Store"}, {Text: "Quantity"}},
+		Rows:    [][]interface{}{{"Amoxicillin", "Central", 10.0}, {"Paracetamol", "Central", 20.0}, {"Zinc", "North", 30.0}},
+	}
+	if err := r.streamTable(TEMPLATE_SHEET, sheet); err != nil {
+		t.Fatalf("streamTable returned an error: %v", err)
+	}
+
+	// the three rows take rows 4 to 6, so the rows below {{rows}} move down by two
+	cells := map[string]string{
+		"A1": "Stock",
+		"A3": "Item",
+		"C3": "Quantity",
+		"A4": "Amoxicillin",
+		"A6": "Zinc",
+		"A8": "Total",
+		"C8": "42",
+		"A9": "Prepared for Stores",
+	}
+	for cell, want := range cells {
+		if got, err := file.GetCellValue(TEMPLATE_SHEET, cell); err != nil || got != want {
+			t.Errorf("%s = %q (%v), want %q", cell, got, err, want)
+		}
+	}
+
+	merges, err := file.GetMergeCells(TEMPLATE_SHEET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, merge := range merges {
+		got = append(got, merge.GetStartAxis()+":"+merge.GetEndAxis())
+	}
+	sort.Strings(got)
+	if want := []string{"A1:C1", "A8:B8", "A9:C10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("merged cells = %v, want %v", got, want)
+	}
+}
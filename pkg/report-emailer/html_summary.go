@@ -0,0 +1,70 @@
+package reportEmailer
+
+import (
+	"bytes"
+	"html/template"
+)
+
+var htmlSummaryTemplate = template.Must(template.New("summary").Parse(`{{if .Description}}<div style="font-family: sans-serif; white-space: pre-wrap;">{{.Description}}</div>
+{{end}}{{range .Sheets}}<h3 style="font-family: sans-serif;">{{.Title}}</h3>
+{{if .Rows}}<table style="border-collapse: collapse; font-family: sans-serif; font-size: 13px;">
+{{if .Headers}}<tr>{{range .Headers}}<th style="border: 1px solid #ccc; padding: 4px 8px; background: #eee; text-align: left;">{{.}}</th>{{end}}</tr>
+{{end}}{{range .Rows}}<tr>{{range .}}<td style="border: 1px solid #ccc; padding: 4px 8px;{{if .Number}} text-align: right;{{end}}">{{.Text}}</td>{{end}}</tr>
+{{end}}</table>
+{{if .Truncated}}<p style="font-family: sans-serif; font-size: 12px; color: #666;">Showing the first {{len .Rows}} of {{.Total}} rows.</p>
+{{end}}{{else}}<p style="font-family: sans-serif;">No data</p>
+{{end}}{{end}}<p style="font-family: sans-serif; font-size: 12px; color: #666;">See the attachment for the full data.</p>
+`))
+
+type htmlSummary struct {
+	Description string
+	Sheets      []htmlSummarySheet
+}
+
+type htmlSummarySheet struct {
+	Title     string
+	Headers   []string
+	Rows      [][]htmlSummaryCell
+	Total     int
+	Truncated bool
+}
+
+type htmlSummaryCell struct {
+	Text   string
+	Number bool
+}
+
+// HtmlSummary returns the email body for the report: the description, escaped and with its line
+// breaks kept, followed by a table of the first rows of each sheet and a note pointing to the attachment.
+func (r *Report) HtmlSummary(description string, rows int) (string, error) {
+	summary := htmlSummary{Description: description}
+	for _, sheet := range r.sheets {
+		summarySheet := htmlSummarySheet{Title: sheet.Title, Total: len(sheet.Rows)}
+		for _, column := range sheet.Columns {
+			summarySheet.Headers = append(summarySheet.Headers, column.Text)
+		}
+
+		for i, row := range sheet.Rows {
+			if i >= rows {
+				summarySheet.Truncated = true
+				break
+			}
+
+			cells := make([]htmlSummaryCell, len(row))
+			for j, value := range row {
+				text, isNumber := r.displayValue(value, columnAt(sheet.Columns, j))
+				cells[j] = htmlSummaryCell{Text: text, Number: isNumber}
+			}
+			summarySheet.Rows = append(summarySheet.Rows, cells)
+		}
+
+		summary.Sheets = append(summary.Sheets, summarySheet)
+	}
+
+	var body bytes.Buffer
+	if err := htmlSummaryTemplate.Execute(&body, summary); err != nil {
+		return "", err
+	}
+
+	return body.String(), nil
+}
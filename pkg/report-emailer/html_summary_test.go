@@ -0,0 +1,63 @@
+package reportEmailer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"excel-report-email-scheduler/pkg/api"
+)
+
+func TestHtmlSummary(t *testing.T) {
+	r := &Report{location: time.UTC, sheets: []api.TablePanel{
+		{
+			Title:   "Stock <North>",
+			Columns: []api.Column{{Text: "Item"}, {Text: "Quantity", Type: "number"}},
+			Rows:    [][]interface{}{{"Amoxicillin & co", 10.0}, {"Zinc", 20.0}, {"ORS", 30.0}},
+		},
+		{Title: "Orders"},
+	}}
+
+	body, err := r.HtmlSummary("Stock is <b>low</b>\nOrder by Friday", 2)
+	if err != nil {
+		t.Fatalf("HtmlSummary returned an error: %v", err)
+	}
+
+	for _, want := range []string{
+		`white-space: pre-wrap;">Stock is &lt;b&gt;low&lt;/b&gt;` + "\nOrder by Friday</div>",
+		`<h3 style="font-family: sans-serif;">Stock &lt;North&gt;</h3>`,
+		">Item</th>",
+		">Quantity</th>",
+		">Amoxicillin &amp; co</td>",
+		`text-align: right;">10</td>`,
+		`text-align: right;">20</td>`,
+		"Showing the first 2 of 3 rows.",
+		`<h3 style="font-family: sans-serif;">Orders</h3>`,
+		"No data",
+		"See the attachment for the full data.",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("the summary does not contain %s:\n%s", want, body)
+		}
+	}
+
+	for _, unwanted := range []string{"<b>", ">ORS<"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("the summary contains %s:\n%s", unwanted, body)
+		}
+	}
+}
+
+func TestHtmlSummaryWithoutDescription(t *testing.T) {
+	r := &Report{location: time.UTC, sheets: []api.TablePanel{{Title: "Stock"}}}
+	body, err := r.HtmlSummary("", 5)
+	if err != nil {
+		t.Fatalf("HtmlSummary returned an error: %v", err)
+	}
+	if strings.Contains(body, "<div") {
+		t.Errorf("the summary has a description without one:\n%s", body)
+	}
+	if strings.Contains(body, "Showing the first") {
+		t.Errorf("the summary says it is cut short:\n%s", body)
+	}
+}
@@ -0,0 +1,152 @@
+package reportEmailer
+
+import (
+	"errors"
+	"excel-report-email-scheduler/pkg/auth"
+	"excel-report-email-scheduler/pkg/datasource"
+	"fmt"
+	"sync"
+
+	"github.com/bugsnag/bugsnag-go"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const DEFAULT_REPORT_WORKERS = 2
+
+var ErrScheduleInProgress = errors.New("a run of this schedule is already queued or in progress")
+var ErrQueueClosed = errors.New("the report queue has been shut down")
+
+// job is a single run of a schedule waiting for, or being processed by, a worker.
+type job struct {
+	run          *datasource.ScheduleRun
+	schedule     datasource.Schedule
+	authConfig   *auth.AuthConfig
+	datasourceID int
+	emailer      Emailer
+	options      RunOptions
+	// scheduled jobs move their schedule on to its next report time, or hold it back for a retry
+	scheduled bool
+	// result, when set, receives the outcome of the job
+	result chan error
+}
+
+// JobQueue runs jobs on a fixed number of workers. At most one job per schedule is
+// queued or running at any time.
+type JobQueue struct {
+	mutex   sync.Mutex
+	ready   *sync.Cond
+	pending []*job
+	active  map[string]bool
+	closed  bool
+	workers sync.WaitGroup
+	process func(*job)
+	// abandon finishes the run of a job which will not be processed, or whose processing panicked
+	abandon func(*job, error)
+}
+
+func NewJobQueue(workers int, process func(*job), abandon func(*job, error)) *JobQueue {
+	if workers <= 0 {
+		workers = DEFAULT_REPORT_WORKERS
+	}
+
+	queue := &JobQueue{active: make(map[string]bool), process: process, abandon: abandon}
+	queue.ready = sync.NewCond(&queue.mutex)
+
+	for i := 0; i < workers; i++ {
+		queue.workers.Add(1)
+		go queue.work()
+	}
+
+	return queue
+}
+
+// Enqueue reserves the schedule and adds the job created by newJob to the queue. newJob is
+// only called once the schedule is known not to be queued or running already. A job created
+// after the queue has been shut down is abandoned.
+func (queue *JobQueue) Enqueue(scheduleID string, newJob func() (*job, error)) error {
+	queue.mutex.Lock()
+	if queue.closed {
+		queue.mutex.Unlock()
+		return ErrQueueClosed
+	}
+	if queue.active[scheduleID] {
+		queue.mutex.Unlock()
+		return ErrScheduleInProgress
+	}
+	queue.active[scheduleID] = true
+	queue.mutex.Unlock()
+
+	j, err := newJob()
+	if err != nil {
+		queue.release(scheduleID)
+		return err
+	}
+
+	queue.mutex.Lock()
+	if queue.closed {
+		delete(queue.active, scheduleID)
+		queue.mutex.Unlock()
+		queue.abandon(j, ErrQueueClosed)
+		return ErrQueueClosed
+	}
+	queue.pending = append(queue.pending, j)
+	queue.ready.Signal()
+	queue.mutex.Unlock()
+
+	return nil
+}
+
+// Shutdown stops the queue from taking new jobs and waits for the jobs being processed to
+// finish. Jobs which had not been picked up by a worker are returned.
+func (queue *JobQueue) Shutdown() []*job {
+	queue.mutex.Lock()
+	queue.closed = true
+	cancelled := queue.pending
+	queue.pending = nil
+	queue.ready.Broadcast()
+	queue.mutex.Unlock()
+
+	queue.workers.Wait()
+
+	return cancelled
+}
+
+func (queue *JobQueue) work() {
+	defer queue.workers.Done()
+
+	for {
+		queue.mutex.Lock()
+		for len(queue.pending) == 0 && !queue.closed {
+			queue.ready.Wait()
+		}
+		if queue.closed {
+			queue.mutex.Unlock()
+			return
+		}
+		j := queue.pending[0]
+		queue.pending = queue.pending[1:]
+		queue.mutex.Unlock()
+
+		queue.processSafely(j)
+		queue.release(j.schedule.ID)
+	}
+}
+
+func (queue *JobQueue) processSafely(j *job) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err := fmt.Errorf("panic while running schedule %s: %v", j.schedule.ID, recovered)
+			log.DefaultLogger.Error("JobQueue.work: " + err.Error())
+			bugsnag.Notify(err)
+			queue.abandon(j, err)
+		}
+	}()
+
+	queue.process(j)
+}
+
+func (queue *JobQueue) release(scheduleID string) {
+	queue.mutex.Lock()
+	delete(queue.active, scheduleID)
+	queue.mutex.Unlock()
+}
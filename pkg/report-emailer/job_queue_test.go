@@ -0,0 +1,150 @@
+package reportEmailer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"excel-report-email-scheduler/pkg/datasource"
+)
+
+func newTestJob(scheduleID string) func() (*job, error) {
+	return func() (*job, error) {
+		return &job{run: &datasource.ScheduleRun{ScheduleID: scheduleID}, schedule: datasource.Schedule{ID: scheduleID}}, nil
+	}
+}
+
+// waitFor fails the test if nothing is received from done within a second.
+func waitFor(t *testing.T, done <-chan string, what string) string {
+	t.Helper()
+	select {
+	case value := <-done:
+		return value
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+		return ""
+	}
+}
+
+func TestJobQueueRejectsActiveSchedule(t *testing.T) {
+	started := make(chan string, 1)
+	unblock := make(chan struct{})
+	queue := NewJobQueue(1, func(j *job) {
+		started <- j.schedule.ID
+		<-unblock
+	}, func(*job, error) {})
+	defer queue.Shutdown()
+
+	if err := queue.Enqueue("a", newTestJob("a")); err != nil {
+		t.Fatalf("Enqueue returned an error: %v", err)
+	}
+	waitFor(t, started, "the first job to start")
+
+	if err := queue.Enqueue("a", newTestJob("a")); !errors.Is(err, ErrScheduleInProgress) {
+		t.Errorf("Enqueue of a running schedule returned %v, want ErrScheduleInProgress", err)
+	}
+	close(unblock)
+}
+
+func TestJobQueueReleasesScheduleAfterPanic(t *testing.T) {
+	processed := make(chan string, 1)
+	abandoned := make(chan string, 1)
+	queue := NewJobQueue(1, func(j *job) {
+		if j.options.OnlyMe {
+			processed <- j.schedule.ID
+			return
+		}
+		panic("boom")
+	}, func(j *job, err error) {
+		j.run.Finish(err)
+		abandoned <- j.run.Status
+	})
+	defer queue.Shutdown()
+
+	if err := queue.Enqueue("a", newTestJob("a")); err != nil {
+		t.Fatalf("Enqueue returned an error: %v", err)
+	}
+	if status := waitFor(t, abandoned, "the panicking job to be abandoned"); status != datasource.ScheduleRunStatusFailed {
+		t.Errorf("the panicking job's run is %s, want %s", status, datasource.ScheduleRunStatusFailed)
+	}
+
+	// the slot is released just after the job is abandoned, so retry until it is free
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := queue.Enqueue("a", func() (*job, error) {
+			j, _ := newTestJob("a")()
+			j.options.OnlyMe = true
+			return j, nil
+		})
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrScheduleInProgress) || time.Now().After(deadline) {
+			t.Fatalf("Enqueue after a panic returned %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	waitFor(t, processed, "the next job of the schedule")
+}
+
+func TestJobQueueShutdownReturnsPendingJobs(t *testing.T) {
+	started := make(chan string, 1)
+	unblock := make(chan struct{})
+	queue := NewJobQueue(1, func(j *job) {
+		started <- j.schedule.ID
+		<-unblock
+	}, func(*job, error) {})
+
+	if err := queue.Enqueue("a", newTestJob("a")); err != nil {
+		t.Fatalf("Enqueue returned an error: %v", err)
+	}
+	waitFor(t, started, "the first job to start")
+	for _, id := range []string{"b", "c"} {
+		if err := queue.Enqueue(id, newTestJob(id)); err != nil {
+			t.Fatalf("Enqueue(%s) returned an error: %v", id, err)
+		}
+	}
+
+	cancelled := make(chan []*job)
+	go func() { cancelled <- queue.Shutdown() }()
+	// let the running job finish only once Shutdown has taken the pending jobs
+	for closed := false; !closed; {
+		queue.mutex.Lock()
+		closed = queue.closed
+		queue.mutex.Unlock()
+	}
+	close(unblock)
+
+	select {
+	case jobs := <-cancelled:
+		if len(jobs) != 2 || jobs[0].schedule.ID != "b" || jobs[1].schedule.ID != "c" {
+			t.Errorf("Shutdown returned %d jobs, want the pending jobs b and c", len(jobs))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Shutdown")
+	}
+
+	if err := queue.Enqueue("d", newTestJob("d")); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Enqueue after Shutdown returned %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestJobQueueAbandonsJobCreatedDuringShutdown(t *testing.T) {
+	abandoned := make(chan string, 1)
+	queue := NewJobQueue(1, func(*job) {}, func(j *job, err error) {
+		if errors.Is(err, ErrQueueClosed) {
+			abandoned <- j.schedule.ID
+		}
+	})
+
+	err := queue.Enqueue("a", func() (*job, error) {
+		queue.Shutdown()
+		return newTestJob("a")()
+	})
+	if !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Enqueue returned %v, want ErrQueueClosed", err)
+	}
+	if id := waitFor(t, abandoned, "the job to be abandoned"); id != "a" {
+		t.Errorf("abandoned the job of %s, want a", id)
+	}
+}
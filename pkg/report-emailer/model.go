@@ -3,13 +3,16 @@ package reportEmailer
 import (
 	"excel-report-email-scheduler/pkg/api"
 	"excel-report-email-scheduler/pkg/datasource"
+	"time"
 
-	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/robfig/cron"
+	"github.com/xuri/excelize/v2"
 )
 
 type ReportEmailer struct {
 	datasource *datasource.MsupplyEresDatasource
-	inProgress bool
+	queue      *JobQueue
+	scheduler  *cron.Cron
 }
 
 type Emailer struct {
@@ -30,4 +33,33 @@ type Report struct {
 	templatePath string
 	file         *excelize.File
 	sheets       []api.TablePanel
+	location     *time.Location
+	// sheetErrors holds, by sheet index, the sheets whose data could not be fetched
+	sheetErrors map[int]error
+	format      ReportFormat
+	// styles caches the styles made for numbers and dates in the workbook
+	styles      map[styleKey]int
+	details     ReportDetails
+	generatedAt time.Time
+	// path is the file the report was last written to
+	path string
+	// summarySheet adds a first sheet to the workbook listing each panel's sheet
+	summarySheet bool
+	// grafanaURL is where the dashboards of the report's panels are found
+	grafanaURL string
+	// layout is how the panels are laid out in the workbook, a sheet for each unless stacked
+	layout string
+}
+
+// RunOptions narrows down who receives a manually requested run of a schedule.
+type RunOptions struct {
+	// Recipients limits the run to these members of the schedule's report group.
+	Recipients []string `json:"recipients"`
+	// OnlyMe sends the report to the user who requested the run instead of the report group.
+	OnlyMe         bool   `json:"onlyMe"`
+	RequesterEmail string `json:"-"`
+	// delivered lists the addresses an earlier attempt at the same report time already reached.
+	delivered []string
+	// reference is the report time a scheduled run is for. The zero time means the run was requested by hand.
+	reference time.Time
 }
@@ -0,0 +1,116 @@
+package reportEmailer
+
+import (
+	"excel-report-email-scheduler/pkg/api"
+	"strings"
+	"time"
+)
+
+// CURRENCY_SYMBOLS are the symbols of Grafana's currency units.
+var CURRENCY_SYMBOLS = map[string]string{
+	"currencyUSD": "$",
+	"currencyGBP": "£",
+	"currencyEUR": "€",
+	"currencyJPY": "¥",
+	"currencyRUB": "₽",
+	"currencyUAH": "₴",
+	"currencyBRL": "R$",
+	"currencyDKK": "kr",
+	"currencyISK": "kr",
+	"currencyNOK": "kr",
+	"currencySEK": "kr",
+	"currencyCZK": "czk",
+	"currencyCHF": "CHF",
+	"currencyPLN": "PLN",
+	"currencyZAR": "R",
+	"currencyINR": "₹",
+	"currencyKRW": "₩",
+	"currencyIDR": "Rp",
+	"currencyPHP": "PHP",
+	"currencyVND": "đ",
+}
+
+// TIME_FORMATS are the Excel formats of Grafana's date and time units.
+var TIME_FORMATS = map[string]string{
+	"dateTimeAsIso":              "yyyy-mm-dd hh:mm:ss",
+	"dateTimeAsIsoNoDateIfToday": "yyyy-mm-dd hh:mm:ss",
+	"dateTimeAsUS":               "mm/dd/yyyy h:mm:ss AM/PM",
+	"dateTimeAsUSNoDateIfToday":  "mm/dd/yyyy h:mm:ss AM/PM",
+	"dateTimeAsLocal":            "yyyy-mm-dd hh:mm:ss",
+	"dateTimeAsSystem":           "yyyy-mm-dd hh:mm:ss",
+	"dateTimeFromNow":            "yyyy-mm-dd hh:mm:ss",
+}
+
+// momentLayout converts the parts of a moment.js layout, which Grafana's time: units use, to Excel's.
+var momentLayout = strings.NewReplacer("YYYY", "yyyy", "YY", "yy", "DD", "dd", "Do", "d", "HH", "hh", "A", "AM/PM", "a", "AM/PM")
+
+// columnFormat is how the values of a column are written to Excel, following the unit and
+// decimals the column has on the dashboard.
+type columnFormat struct {
+	// numFmt is the Excel number format of the column, empty for the default format
+	numFmt string
+	// isTime is true when the column's numbers are unix times in milliseconds
+	isTime bool
+	// guessDates is true unless the column is known to hold numbers, so values which
+	// look like dates are written as dates
+	guessDates bool
+}
+
+func newColumnFormat(column api.Column) columnFormat {
+	unit := column.Unit
+
+	if layout, ok := TIME_FORMATS[unit]; ok {
+		return columnFormat{numFmt: layout, isTime: true}
+	}
+	if layout := strings.TrimPrefix(unit, "time:"); layout != unit {
+		return columnFormat{numFmt: momentLayout.Replace(layout), isTime: true}
+	}
+
+	format := columnFormat{guessDates: column.Type != "number" && (unit == "" || unit == "none" || unit == "short")}
+
+	decimals := 2
+	if column.Decimals != nil && *column.Decimals >= 0 {
+		decimals = *column.Decimals
+	}
+	number := "0"
+	if decimals > 0 {
+		number += "." + strings.Repeat("0", decimals)
+	}
+
+	switch {
+	case unit == "percent":
+		format.numFmt = number + `"%"`
+	case unit == "percentunit":
+		format.numFmt = number + "%"
+	case unit == "locale":
+		format.numFmt = "#,##" + number
+	case CURRENCY_SYMBOLS[unit] != "":
+		format.numFmt = quoteFormatText(CURRENCY_SYMBOLS[unit]) + "#,##" + number
+	case strings.HasPrefix(unit, "currency:"):
+		format.numFmt = quoteFormatText(strings.TrimPrefix(unit, "currency:")) + "#,##" + number
+	case strings.HasPrefix(unit, "prefix:"):
+		format.numFmt = quoteFormatText(strings.TrimPrefix(unit, "prefix:")) + number
+	case strings.HasPrefix(unit, "suffix:"):
+		format.numFmt = number + quoteFormatText(strings.TrimPrefix(unit, "suffix:"))
+	case column.Decimals != nil:
+		format.numFmt = number
+	}
+
+	return format
+}
+
+func quoteFormatText(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, "") + `"`
+}
+
+// excelTime converts a unix time in milliseconds to the time Excel shows in the location.
+// Excel has no time zones, so the time is given with the location's wall clock in UTC.
+func excelTime(value interface{}, location *time.Location) (time.Time, bool) {
+	milliseconds, ok := value.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t := time.UnixMilli(int64(milliseconds)).In(location)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), true
+}
@@ -0,0 +1,73 @@
+package reportEmailer
+
+import (
+	"testing"
+	"time"
+
+	"excel-report-email-scheduler/pkg/api"
+)
+
+func TestNewColumnFormat(t *testing.T) {
+	decimals := func(n int) *int { return &n }
+
+	tests := []struct {
+		name   string
+		column api.Column
+		want   columnFormat
+	}{
+		{name: "no unit guesses dates", column: api.Column{}, want: columnFormat{guessDates: true}},
+		{name: "number column does not guess dates", column: api.Column{Type: "number"}, want: columnFormat{}},
+		{name: "short unit guesses dates", column: api.Column{Unit: "short"}, want: columnFormat{guessDates: true}},
+		{name: "decimals without a unit", column: api.Column{Decimals: decimals(3)}, want: columnFormat{numFmt: "0.000", guessDates: true}},
+		{name: "negative decimals use two", column: api.Column{Decimals: decimals(-1)}, want: columnFormat{numFmt: "0.00", guessDates: true}},
+		{name: "dollars", column: api.Column{Unit: "currencyUSD"}, want: columnFormat{numFmt: `"$"#,##0.00`}},
+		{name: "euros without decimals", column: api.Column{Unit: "currencyEUR", Decimals: decimals(0)}, want: columnFormat{numFmt: `"€"#,##0`}},
+		{name: "custom currency", column: api.Column{Unit: "currency:NZ$"}, want: columnFormat{numFmt: `"NZ$"#,##0.00`}},
+		{name: "percent", column: api.Column{Unit: "percent", Decimals: decimals(1)}, want: columnFormat{numFmt: `0.0"%"`}},
+		{name: "percent of one", column: api.Column{Unit: "percentunit"}, want: columnFormat{numFmt: "0.00%"}},
+		{name: "locale", column: api.Column{Unit: "locale"}, want: columnFormat{numFmt: "#,##0.00"}},
+		{name: "prefix", column: api.Column{Unit: "prefix:~"}, want: columnFormat{numFmt: `"~"0.00`}},
+		{name: "suffix with a quote", column: api.Column{Unit: `suffix: "boxes"`}, want: columnFormat{numFmt: `0.00" boxes"`}},
+		{name: "ISO date and time", column: api.Column{Unit: "dateTimeAsIso"}, want: columnFormat{numFmt: "yyyy-mm-dd hh:mm:ss", isTime: true}},
+		{name: "US date and time", column: api.Column{Unit: "dateTimeAsUS"}, want: columnFormat{numFmt: "mm/dd/yyyy h:mm:ss AM/PM", isTime: true}},
+		{name: "custom time layout", column: api.Column{Unit: "time:DD/MM/YYYY HH:mm"}, want: columnFormat{numFmt: "dd/MM/yyyy hh:mm", isTime: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := newColumnFormat(test.column); got != test.want {
+				t.Errorf("newColumnFormat = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExcelTime(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	milliseconds := float64(time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC).UnixMilli())
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		location *time.Location
+		want     time.Time
+		ok       bool
+	}{
+		{name: "UTC", value: milliseconds, location: time.UTC, want: time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC), ok: true},
+		{name: "wall clock of the location", value: milliseconds, location: auckland, want: time.Date(2024, 5, 15, 22, 0, 0, 0, time.UTC), ok: true},
+		{name: "text is not a time", value: "2024-05-15", location: time.UTC},
+		{name: "nil is not a time", value: nil, location: time.UTC},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := excelTime(test.value, test.location)
+			if ok != test.ok || !got.Equal(test.want) || (ok && got.Location() != time.UTC) {
+				t.Errorf("excelTime = %s, %v, want %s, %v", got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package reportEmailer
+
+import (
+	_ "embed"
+	"excel-report-email-scheduler/pkg/api"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const (
+	pdfMargin       = 10.0
+	pdfRowHeight    = 6.0
+	pdfCellPadding  = 1.5
+	pdfFontSize     = 8.0
+	pdfTitleSize    = 14.0
+	pdfMaxCellWidth = 80.0
+	pdfFont         = "report"
+)
+
+// Layouts of the dates and times shown in the PDF report and the email. Dates are written as
+// the CSV report writes them.
+const (
+	displayDateLayout = "2006/01/02"
+	displayTimeLayout = "2006/01/02 15:04:05"
+)
+
+// The PDF is written in DejaVu Sans, which covers Latin, Greek, Cyrillic and Georgian text among
+// others. A font for other scripts can be put in the plugin's data folder as report-font.ttf,
+// with report-font-bold.ttf for its bold weight, to be used in its place.
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	pdfRegularFont []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	pdfBoldFont []byte
+)
+
+// writePdf writes each sheet of the report to its own pages of an A4 landscape PDF, with the
+// sheet's title and the date above a table whose headers are repeated on every page.
+func (r *Report) writePdf(path string) error {
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	pdf.AliasNbPages("")
+	pdf.AddUTF8FontFromBytes(pdfFont, "", pdfFontFile("report-font", pdfRegularFont))
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", pdfFontFile("report-font-bold", pdfBoldFont))
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pdfMargin)
+		pdf.SetFont(pdfFont, "", pdfFontSize)
+		pdf.CellFormat(0, pdfRowHeight, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	if len(r.sheets) == 0 {
+		pdf.AddPage()
+	}
+
+	for _, sheet := range r.sheets {
+		log.DefaultLogger.Info(fmt.Sprintf("Creating new PDF section %s", sheet.Title))
+		r.writePdfSheet(pdf, sheet)
+	}
+
+	return pdf.OutputFileAndClose(path)
+}
+
+// pdfFontFile returns the font with the given name from the plugin's data folder, or the
+// embedded font when there is none.
+func pdfFontFile(name string, embedded []byte) []byte {
+	content, err := os.ReadFile(GetFilePathWithExtension(name, "ttf"))
+	if err != nil {
+		return embedded
+	}
+
+	log.DefaultLogger.Info("Writing PDF with font " + name)
+	return content
+}
+
+func (r *Report) writePdfSheet(pdf *fpdf.Fpdf, sheet api.TablePanel) {
+	pdf.AddPage()
+
+	pdf.SetFont(pdfFont, "B", pdfTitleSize)
+	pdf.CellFormat(0, 8, sheet.Title, "", 1, "L", false, 0, "")
+	pdf.SetFont(pdfFont, "", pdfFontSize)
+	pdf.CellFormat(0, pdfRowHeight, r.formatTime(r.generatedAt, "", DEFAULT_DATE_LAYOUT), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	if len(sheet.Rows) == 0 {
+		pdf.CellFormat(0, pdfRowHeight, "No data", "", 1, "L", false, 0, "")
+		return
+	}
+
+	headers := make([]string, len(sheet.Columns))
+	for i, column := range sheet.Columns {
+		headers[i] = column.Text
+	}
+
+	rows := make([][]string, len(sheet.Rows))
+	numeric := make([][]bool, len(sheet.Rows))
+	for i, row := range sheet.Rows {
+		rows[i] = make([]string, len(row))
+		numeric[i] = make([]bool, len(row))
+		for j, value := range row {
+			text, isNumber := r.displayValue(value, columnAt(sheet.Columns, j))
+			rows[i][j] = text
+			numeric[i][j] = isNumber
+		}
+	}
+
+	widths := pdfColumnWidths(pdf, headers, rows)
+	_, pageHeight := pdf.GetPageSize()
+	bottom := pageHeight - pdfMargin - pdfRowHeight
+
+	writePdfHeaders(pdf, headers, widths)
+	for i, row := range rows {
+		if pdf.GetY()+pdfRowHeight > bottom {
+			pdf.AddPage()
+			writePdfHeaders(pdf, headers, widths)
+		}
+
+		fill := i%2 == 1
+		pdf.SetFillColor(245, 245, 245)
+		for j, width := range widths {
+			text, align := "", "L"
+			if j < len(row) {
+				text = fitPdfText(pdf, row[j], width)
+				if numeric[i][j] {
+					align = "R"
+				}
+			}
+			pdf.CellFormat(width, pdfRowHeight, text, "1", 0, align, fill, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
+
+func writePdfHeaders(pdf *fpdf.Fpdf, headers []string, widths []float64) {
+	if len(headers) == 0 {
+		return
+	}
+
+	pdf.SetFont(pdfFont, "B", pdfFontSize)
+	pdf.SetFillColor(220, 220, 220)
+	for i, width := range widths {
+		text := ""
+		if i < len(headers) {
+			text = fitPdfText(pdf, headers[i], width)
+		}
+		pdf.CellFormat(width, pdfRowHeight, text, "1", 0, "L", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont(pdfFont, "", pdfFontSize)
+}
+
+// pdfColumnWidths sizes each column to its widest value, up to pdfMaxCellWidth, and shrinks
+// the columns evenly when they do not fit across the page.
+func pdfColumnWidths(pdf *fpdf.Fpdf, headers []string, rows [][]string) []float64 {
+	columns := len(headers)
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	widths := make([]float64, columns)
+	pdf.SetFont(pdfFont, "B", pdfFontSize)
+	for i, header := range headers {
+		widths[i] = pdf.GetStringWidth(header)
+	}
+	pdf.SetFont(pdfFont, "", pdfFontSize)
+	for _, row := range rows {
+		for i, text := range row {
+			widths[i] = math.Max(widths[i], pdf.GetStringWidth(text))
+		}
+	}
+
+	total := 0.0
+	for i := range widths {
+		widths[i] = math.Min(widths[i]+2*pdfCellPadding, pdfMaxCellWidth)
+		total += widths[i]
+	}
+
+	pageWidth, _ := pdf.GetPageSize()
+	available := pageWidth - 2*pdfMargin
+	if total > available {
+		for i := range widths {
+			widths[i] *= available / total
+		}
+	}
+
+	return widths
+}
+
+// fitPdfText shortens text with an ellipsis until it fits in a cell of the given width.
+func fitPdfText(pdf *fpdf.Fpdf, text string, width float64) string {
+	// columns are sized to their widest value exactly, so allow for rounding
+	available := width - 2*pdfCellPadding + 0.01
+	if pdf.GetStringWidth(text) <= available {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > available {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// displayValue formats a value for the PDF report and the email, with the decimals of its
+// column when the dashboard sets them, and reports whether it is a number. Numbers are only
+// shown as dates in columns of times, by their type or their unit.
+func (r *Report) displayValue(value interface{}, column api.Column) (string, bool) {
+	if value == nil {
+		return "", false
+	}
+
+	if t, ok := excelTime(value, r.location); ok {
+		if newColumnFormat(column).isTime {
+			return t.Format(displayTimeLayout), false
+		}
+		if column.Type == "time" {
+			return t.Format(displayDateLayout), false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if date, ok := toDateString(v); ok {
+			return date, false
+		}
+		return v, false
+	case float64:
+		if column.Decimals != nil && *column.Decimals >= 0 {
+			return strconv.FormatFloat(v, 'f', *column.Decimals, 64), true
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), false
+	}
+
+	return fmt.Sprint(value), false
+}
+
+// columnAt returns the i'th column, or a column without settings for values beyond the last.
+func columnAt(columns []api.Column, i int) api.Column {
+	if i >= len(columns) {
+		return api.Column{}
+	}
+	return columns[i]
+}
@@ -0,0 +1,145 @@
+package reportEmailer
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"excel-report-email-scheduler/pkg/api"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// newTestPdf returns a PDF set up with the report's fonts, as writePdf sets it up.
+func newTestPdf(t *testing.T) *fpdf.Fpdf {
+	t.Helper()
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(pdfFont, "", pdfRegularFont)
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", pdfBoldFont)
+	pdf.SetFont(pdfFont, "", pdfFontSize)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return pdf
+}
+
+func TestDisplayValue(t *testing.T) {
+	two := 2
+	milliseconds := float64(time.Date(2024, 5, 15, 10, 30, 0, 0, time.UTC).UnixMilli())
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		column   api.Column
+		want     string
+		isNumber bool
+	}{
+		{name: "nil", value: nil, want: ""},
+		{name: "text", value: "Central", want: "Central"},
+		{name: "RFC 3339 text is a date", value: "2024-05-15T10:30:00Z", want: "2024/05/15"},
+		{name: "number", value: 12.5, want: "12.5", isNumber: true},
+		{name: "number with the column's decimals", value: 12.5, column: api.Column{Decimals: &two}, want: "12.50", isNumber: true},
+		{name: "number in the range of dates is a number", value: 1500000000000.0, want: "1500000000000", isNumber: true},
+		{name: "number in a number column", value: milliseconds, column: api.Column{Type: "number"}, want: "1715769000000", isNumber: true},
+		{name: "time column", value: milliseconds, column: api.Column{Type: "time"}, want: "2024/05/15"},
+		{name: "time unit", value: milliseconds, column: api.Column{Unit: "dateTimeAsIso"}, want: "2024/05/15 10:30:00"},
+		{name: "boolean", value: true, want: "true"},
+	}
+
+	r := &Report{location: time.UTC}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, isNumber := r.displayValue(test.value, test.column)
+			if got != test.want || isNumber != test.isNumber {
+				t.Errorf("displayValue(%v) = %q, %v, want %q, %v", test.value, got, isNumber, test.want, test.isNumber)
+			}
+		})
+	}
+}
+
+func TestPdfColumnWidths(t *testing.T) {
+	pdf := newTestPdf(t)
+	pageWidth, _ := pdf.GetPageSize()
+	available := pageWidth - 2*pdfMargin
+
+	t.Run("columns fit their widest value", func(t *testing.T) {
+		widths := pdfColumnWidths(pdf, []string{"Item", "Quantity"}, [][]string{{"Amoxicillin 250mg", "1"}, {"Zinc", "20"}})
+		if len(widths) != 2 {
+			t.Fatalf("pdfColumnWidths returned %d widths, want 2", len(widths))
+		}
+		pdf.SetFont(pdfFont, "", pdfFontSize)
+		if want := pdf.GetStringWidth("Amoxicillin 250mg") + 2*pdfCellPadding; math.Abs(widths[0]-want) > 0.001 {
+			t.Errorf("the item column is %.2f wide, want %.2f", widths[0], want)
+		}
+		pdf.SetFont(pdfFont, "B", pdfFontSize)
+		if want := pdf.GetStringWidth("Quantity") + 2*pdfCellPadding; math.Abs(widths[1]-want) > 0.001 {
+			t.Errorf("the quantity column is %.2f wide, want the width of its header %.2f", widths[1], want)
+		}
+	})
+
+	t.Run("long values are capped", func(t *testing.T) {
+		widths := pdfColumnWidths(pdf, []string{"Notes"}, [][]string{{strings.Repeat("long notes ", 50)}})
+		if widths[0] != pdfMaxCellWidth {
+			t.Errorf("the notes column is %.2f wide, want %.2f", widths[0], pdfMaxCellWidth)
+		}
+	})
+
+	t.Run("columns shrink to fit the page", func(t *testing.T) {
+		headers := make([]string, 8)
+		for i := range headers {
+			headers[i] = strings.Repeat("wide header ", 10)
+		}
+		total := 0.0
+		for _, width := range pdfColumnWidths(pdf, headers, nil) {
+			total += width
+		}
+		if math.Abs(total-available) > 0.001 {
+			t.Errorf("the columns are %.2f wide in all, want the %.2f across the page", total, available)
+		}
+	})
+
+	t.Run("rows with more values than headers", func(t *testing.T) {
+		if widths := pdfColumnWidths(pdf, []string{"Item"}, [][]string{{"a", "b", "c"}}); len(widths) != 3 {
+			t.Errorf("pdfColumnWidths returned %d widths, want 3", len(widths))
+		}
+	})
+}
+
+func TestFitPdfText(t *testing.T) {
+	pdf := newTestPdf(t)
+	width := 30.0
+
+	tests := []struct {
+		name      string
+		text      string
+		shortened bool
+	}{
+		{name: "text which fits", text: "Zinc"},
+		{name: "text which is too long", text: strings.Repeat("Amoxicillin ", 10), shortened: true},
+		{name: "text in Cyrillic", text: strings.Repeat("Склад лекарств ", 10), shortened: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := fitPdfText(pdf, test.text, width)
+			if !test.shortened {
+				if got != test.text {
+					t.Errorf("fitPdfText(%q) = %q, want it unchanged", test.text, got)
+				}
+				return
+			}
+
+			if !strings.HasSuffix(got, "...") || !strings.HasPrefix(test.text, strings.TrimSuffix(got, "...")) {
+				t.Errorf("fitPdfText(%q) = %q, want the start of the text and an ellipsis", test.text, got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("fitPdfText(%q) = %q, which splits a character", test.text, got)
+			}
+			if textWidth := pdf.GetStringWidth(got); textWidth > width-2*pdfCellPadding+0.01 {
+				t.Errorf("fitPdfText(%q) = %q, %.2f wide, which does not fit in %.2f", test.text, got, textWidth, width)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package reportEmailer
+
+import (
+	"excel-report-email-scheduler/pkg/api"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// PLACEHOLDER_REG matches a placeholder such as {{title}} or {{period.from:2 January 2006}},
+// where anything after the colon is the Go layout the value is formatted with.
+var PLACEHOLDER_REG = regexp.MustCompile(`\{\{\s*([A-Za-z][\w.-]*)\s*(?::([^}]*))?\}\}`)
+
+const (
+	DEFAULT_DATE_LAYOUT   = "Mon Jan 2 15:04:05"
+	DEFAULT_PERIOD_LAYOUT = "2 January 2006"
+)
+
+// ReportDetails are the details of the schedule a report is for which templates can print.
+type ReportDetails struct {
+	ScheduleName        string
+	ScheduleDescription string
+	ReportGroupName     string
+}
+
+func (r *Report) SetDetails(details ReportDetails) {
+	r.details = details
+}
+
+// title returns the name of the schedule the report is for.
+func (r *Report) title() string {
+	if r.details.ScheduleName == "" {
+		return r.name
+	}
+	return r.details.ScheduleName
+}
+
+// placeholderValue returns the value of a placeholder for a sheet of the report. It reports
+// false for placeholders it does not know, such as {{headers}} and {{rows}}, which are left alone.
+func (r *Report) placeholderValue(sheet api.TablePanel, name string, layout string) (interface{}, bool) {
+	switch name {
+	case "title":
+		return sheet.Title, true
+	case "date":
+		return r.formatTime(r.generatedAt, layout, DEFAULT_DATE_LAYOUT), true
+	case "schedule.name":
+		return r.details.ScheduleName, true
+	case "schedule.description":
+		return r.details.ScheduleDescription, true
+	case "reportGroup":
+		return r.details.ReportGroupName, true
+	case "rowCount":
+		return len(sheet.Rows), true
+	case "panel.start", "panel.end":
+		// these only mark the rows written for each panel of a stacked sheet
+		return "", true
+	case "period.from", "period.to":
+		isTo := name == "period.to"
+		value := sheet.From
+		if isTo {
+			value = sheet.To
+		}
+		t, err := api.ParseTime(value, r.generatedAt, isTo)
+		if err != nil {
+			log.DefaultLogger.Error("placeholderValue: ParseTime: " + err.Error())
+			return value, true
+		}
+		return r.formatTime(t, layout, DEFAULT_PERIOD_LAYOUT), true
+	}
+
+	if variable := strings.TrimPrefix(name, "variable."); variable != name {
+		return strings.Join(sheet.SelectedVariables[variable], ", "), true
+	}
+
+	return nil, false
+}
+
+func (r *Report) formatTime(t time.Time, layout string, defaultLayout string) string {
+	if strings.TrimSpace(layout) == "" {
+		layout = defaultLayout
+	}
+	return t.In(r.location).Format(layout)
+}
+
+// fillText replaces the placeholders in the text of a cell with their values for a sheet. Text
+// which is only a placeholder is replaced by the value itself, so a row count stays a number.
+// It reports false when the text has no placeholders to replace.
+func (r *Report) fillText(text string, sheet api.TablePanel) (interface{}, bool) {
+	if !strings.Contains(text, "{{") {
+		return nil, false
+	}
+
+	if match := PLACEHOLDER_REG.FindStringSubmatch(text); match != nil && match[0] == strings.TrimSpace(text) {
+		return r.placeholderValue(sheet, match[1], match[2])
+	}
+
+	filled := PLACEHOLDER_REG.ReplaceAllStringFunc(text, func(placeholder string) string {
+		match := PLACEHOLDER_REG.FindStringSubmatch(placeholder)
+		value, ok := r.placeholderValue(sheet, match[1], match[2])
+		if !ok {
+			return placeholder
+		}
+		if count, isInt := value.(int); isInt {
+			return strconv.Itoa(count)
+		}
+		return value.(string)
+	})
+	return filled, filled != text
+}
+
+// fillPlaceholders replaces the placeholders in each cell of a sheet with their values.
+func (r *Report) fillPlaceholders(sheetName string, sheet api.TablePanel) error {
+	rows, err := r.file.GetRows(sheetName)
+	if err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		for j, text := range row {
+			if value, ok := r.fillText(text, sheet); ok {
+				if err := r.file.SetCellValue(sheetName, r.createCellRef(j, i+1), value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package reportEmailer
+
+import (
+	"testing"
+	"time"
+
+	"excel-report-email-scheduler/pkg/api"
+)
+
+// newPlaceholderReport returns a report generated at 10:30 UTC on 15 May 2024, for a schedule
+// in a time zone three hours ahead, and a sheet for it.
+func newPlaceholderReport() (*Report, api.TablePanel) {
+	r := &Report{
+		location:    time.FixedZone("EAT", 3*60*60),
+		generatedAt: time.Date(2024, 5, 15, 10, 30, 0, 0, time.UTC),
+		details:     ReportDetails{ScheduleName: "Weekly stock", ScheduleDescription: "Stock of each store", ReportGroupName: "Stores"},
+	}
+	sheet := api.TablePanel{
+		Title:             "Stock",
+		From:              "now-7d",
+		To:                "now",
+		Rows:              [][]interface{}{{"Amoxicillin"}, {"Paracetamol"}, {"Zinc"}},
+		SelectedVariables: map[string][]string{"store": {"Central", "North"}},
+	}
+	return r, sheet
+}
+
+func TestPlaceholderValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout string
+		from   string
+		want   interface{}
+		ok     bool
+	}{
+		{name: "title", want: "Stock", ok: true},
+		{name: "schedule.name", want: "Weekly stock", ok: true},
+		{name: "schedule.description", want: "Stock of each store", ok: true},
+		{name: "reportGroup", want: "Stores", ok: true},
+		{name: "rowCount", want: 3, ok: true},
+		{name: "date", want: "Wed May 15 13:30:00", ok: true},
+		{name: "date", layout: "2006-01-02", want: "2024-05-15", ok: true},
+		{name: "period.from", want: "8 May 2024", ok: true},
+		{name: "period.from", layout: "02/01/2006 15:04", want: "08/05/2024 13:30", ok: true},
+		{name: "period.to", layout: "2006-01-02", want: "2024-05-15", ok: true},
+		{name: "period.from", from: "1714521600000", want: "1 May 2024", ok: true},
+		{name: "period.from", from: "last week", want: "last week", ok: true},
+		{name: "variable.store", want: "Central, North", ok: true},
+		{name: "variable.region", want: "", ok: true},
+		{name: "panel.start", want: "", ok: true},
+		{name: "headers", want: nil},
+		{name: "unknown", want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name+":"+test.layout+test.from, func(t *testing.T) {
+			r, sheet := newPlaceholderReport()
+			if test.from != "" {
+				sheet.From = test.from
+			}
+			got, ok := r.placeholderValue(sheet, test.name, test.layout)
+			if got != test.want || ok != test.ok {
+				t.Errorf("placeholderValue(%q, %q) = %#v, %v, want %#v, %v", test.name, test.layout, got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestFillText(t *testing.T) {
+	tests := []struct {
+		text string
+		want interface{}
+		ok   bool
+	}{
+		{text: "Stock", want: nil},
+		{text: "{{title}}", want: "Stock", ok: true},
+		{text: " {{ rowCount }} ", want: 3, ok: true},
+		{text: "{{rowCount}} rows of {{title}}", want: "3 rows of Stock", ok: true},
+		{text: "From {{period.from:2 Jan}} to {{period.to:2 Jan 2006}}", want: "From 8 May to 15 May 2024", ok: true},
+		{text: "Generated {{date:15:04}} for {{reportGroup}}", want: "Generated 13:30 for Stores", ok: true},
+		{text: "Stores: {{variable.store}}", want: "Stores: Central, North", ok: true},
+		{text: "{{unknown}} and {{title}}", want: "{{unknown}} and Stock", ok: true},
+		{text: "{{unknown}} stock", want: "{{unknown}} stock"},
+		{text: "{{headers}}", want: nil},
+		{text: "{{ not a placeholder }}", want: "{{ not a placeholder }}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			r, sheet := newPlaceholderReport()
+			got, ok := r.fillText(test.text, sheet)
+			if ok != test.ok || (ok && got != test.want) {
+				t.Errorf("fillText(%q) = %#v, %v, want %#v, %v", test.text, got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
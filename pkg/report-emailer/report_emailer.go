@@ -1,11 +1,14 @@
 package reportEmailer
 
 import (
+	"errors"
 	"excel-report-email-scheduler/pkg/api"
 	"excel-report-email-scheduler/pkg/auth"
 	"excel-report-email-scheduler/pkg/datasource"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/bugsnag/bugsnag-go"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
@@ -13,13 +16,20 @@ import (
 )
 
 func NewReportEmailer(datasource *datasource.MsupplyEresDatasource) *ReportEmailer {
-	re := ReportEmailer{datasource: datasource, inProgress: false}
+	workers := DEFAULT_REPORT_WORKERS
+	if settings, err := datasource.NewSettings(); err == nil && settings.ReportWorkers > 0 {
+		workers = settings.ReportWorkers
+	}
+
+	re := ReportEmailer{datasource: datasource}
+	re.queue = NewJobQueue(workers, re.processJob, re.abandonJob)
 	re.Init()
 	return &re
 }
 
 func (re *ReportEmailer) Init() {
-	// Try to send reports on loading
+	// Deal with the report times missed while the plugin was not running, then try to send reports on loading
+	re.catchUp()
 	re.CreateReports()
 
 	// Set up scheduler which will try to send reports every 10 minutes
@@ -29,6 +39,73 @@ func (re *ReportEmailer) Init() {
 	})
 
 	c.Start()
+	re.scheduler = c
+}
+
+// catchUp applies each overdue schedule's catch-up policy to the report times it missed.
+// Schedules running once for all of them are left as they are. Schedules skipping them move
+// on to their next report time. Schedules running for each of them are worked through one
+// report time at a time by CreateReports, keeping at most MAX_CATCH_UP_RUNS of the latest.
+func (re *ReportEmailer) catchUp() {
+	schedules, err := re.datasource.OverdueSchedules()
+	if err != nil {
+		log.DefaultLogger.Error("ReportEmailer.catchUp: OverdueSchedules: " + err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, schedule := range schedules {
+		switch schedule.CatchUpPolicy {
+		case datasource.CatchUpPolicySkip:
+			log.DefaultLogger.Info(fmt.Sprintf("Skipping the missed report times of schedule '%s'", schedule.Name))
+			schedule.UpdateNextReportTime()
+
+		case datasource.CatchUpPolicyAll:
+			missed := schedule.MissedReportTimes(now)
+			log.DefaultLogger.Info(fmt.Sprintf("Schedule '%s' is catching up on %d report times", schedule.Name, len(missed)))
+			if len(missed) == 0 || int(missed[0].Unix()) == schedule.NextReportTime {
+				continue
+			}
+			schedule.NextReportTime = int(missed[0].Unix())
+
+		default:
+			continue
+		}
+
+		if _, err := re.datasource.UpdateSchedule(schedule.ID, schedule); err != nil {
+			log.DefaultLogger.Error("ReportEmailer.catchUp: UpdateSchedule: " + err.Error())
+			bugsnag.Notify(err)
+		}
+	}
+}
+
+// Shutdown stops new runs from being scheduled and waits for the runs in progress to finish.
+// Runs still waiting in the queue are marked as failed; their schedules stay overdue, so
+// they are picked up again when the plugin next starts.
+func (re *ReportEmailer) Shutdown() {
+	log.DefaultLogger.Info("Shutting down report emailer...")
+	if re.scheduler != nil {
+		re.scheduler.Stop()
+	}
+
+	for _, j := range re.queue.Shutdown() {
+		re.abandonJob(j, errors.New("the plugin stopped before the run started"))
+	}
+}
+
+// abandonJob records the run of a job which will not finish normally as failed, so it is
+// not left queued or running.
+func (re *ReportEmailer) abandonJob(j *job, err error) {
+	j.run.Finish(err)
+	if updateErr := re.datasource.UpdateScheduleRun(*j.run); updateErr != nil {
+		log.DefaultLogger.Error("ReportEmailer.abandonJob: UpdateScheduleRun: " + updateErr.Error())
+	}
+	if j.result != nil {
+		select {
+		case j.result <- err:
+		default:
+		}
+	}
 }
 
 func (re *ReportEmailer) configs() (*auth.AuthConfig, *auth.EmailConfig, int, error) {
@@ -53,32 +130,228 @@ func (re *ReportEmailer) configs() (*auth.AuthConfig, *auth.EmailConfig, int, er
 	return authConfig, emailConfig, settings.DatasourceID, nil
 }
 
-func (re *ReportEmailer) cleanup(schedules []datasource.Schedule) {
-	log.DefaultLogger.Info("Starting Clean up...")
-	for _, schedule := range schedules {
-
-		log.DefaultLogger.Info(fmt.Sprintf("Deleting %s.xlsx...", schedule.Name))
-		path := GetFilePath(schedule.Name)
+// removeReportFiles deletes the report files of a schedule once a run has sent them.
+func (re *ReportEmailer) removeReportFiles(schedule datasource.Schedule) {
+	// the extension of the report file depends on the schedule's format and number of panels
+	for _, extension := range []string{datasource.ReportFormatXlsx, datasource.ReportFormatCsv, "zip", datasource.ReportFormatPdf} {
+		path := GetFilePathWithExtension(schedule.Name, extension)
 		err := os.Remove(path)
-		if err != nil {
+		if err == nil {
+			log.DefaultLogger.Info(fmt.Sprintf("Deleted %s.%s", schedule.Name, extension))
+		} else if !os.IsNotExist(err) {
 			// Failure case shouldn't be much of a problem since we're using the schedule ID for the report name, at the moment
 			// as it will just write to the same file and not create infinitely many if deleting always fails.
-			log.DefaultLogger.Error(fmt.Sprintf("Could not delete %s... : %s.xlsx", schedule.Name, err.Error()))
+			log.DefaultLogger.Error(fmt.Sprintf("Could not delete %s.%s... : %s", schedule.Name, extension, err.Error()))
+		}
+	}
+}
+
+// cleanup moves the schedule of a scheduled run on to its next report time. A run which needs
+// retrying holds the schedule back until its retry is due instead, until the schedule runs
+// out of attempts.
+func (re *ReportEmailer) cleanup(schedule datasource.Schedule, run *datasource.ScheduleRun) {
+	log.DefaultLogger.Info("Starting Clean up...")
+
+	if run.NeedsRetry() {
+		if run.Attempt < schedule.MaxAttempts() {
+			retryAfter := time.Now().Add(schedule.RetryDelay(run.Attempt))
+			log.DefaultLogger.Info(fmt.Sprintf("Retrying schedule '%s' at '%s' (attempt %d of %d failed)", schedule.Name, retryAfter, run.Attempt, schedule.MaxAttempts()))
+			if err := re.datasource.RetryScheduleAfter(schedule.ID, int(retryAfter.Unix())); err != nil {
+				log.DefaultLogger.Error("ReportEmailer.cleanup: RetryScheduleAfter: " + err.Error())
+				bugsnag.Notify(err)
+			}
+			return
 		}
 
-		schedule.UpdateNextReportTime()
-		re.datasource.UpdateSchedule(schedule.ID, schedule)
+		log.DefaultLogger.Error(fmt.Sprintf("ReportEmailer.cleanup: giving up on schedule '%s' after %d attempts: %s", schedule.Name, run.Attempt, run.Error))
+		run.Status = datasource.ScheduleRunStatusFailed
+		run.Error = fmt.Sprintf("Gave up after %d attempts: %s", run.Attempt, run.Error)
+		if err := re.datasource.UpdateScheduleRun(*run); err != nil {
+			log.DefaultLogger.Error("ReportEmailer.cleanup: UpdateScheduleRun: " + err.Error())
+		}
+	}
+
+	if err := re.moveOn(schedule); err != nil {
+		log.DefaultLogger.Error("ReportEmailer.cleanup: moveOn: " + err.Error())
+		bugsnag.Notify(err)
+	}
+}
+
+// moveOn moves a schedule on from the report time it has run for. The schedule is loaded again
+// first, so edits, pauses and new report times saved while it was queued or running are kept.
+func (re *ReportEmailer) moveOn(schedule datasource.Schedule) error {
+	current, err := re.datasource.GetSchedule(schedule.ID)
+	if err != nil {
+		return err
+	}
+	if current.NextReportTime != schedule.NextReportTime {
+		log.DefaultLogger.Info(fmt.Sprintf("Schedule '%s' was given a new report time while it ran", current.Name))
+		return nil
 	}
 
-	re.inProgress = false
+	current.AdvanceNextReportTime()
+	return re.datasource.AdvanceSchedule(current.ID, schedule.NextReportTime, current.NextReportTime)
 }
 
+// CreateReport runs the schedule through the queue and waits for the run to finish.
 func (re *ReportEmailer) CreateReport(schedule datasource.Schedule, authConfig *auth.AuthConfig, datasourceID int, em Emailer) error {
+	result := make(chan error, 1)
+	_, err := re.enqueue(job{schedule: schedule, authConfig: authConfig, datasourceID: datasourceID, emailer: em, result: result})
+	if err != nil {
+		log.DefaultLogger.Error("ReportEmailer.CreateReport: enqueue: " + err.Error())
+		return err
+	}
 
-	log.DefaultLogger.Debug("ReportEmailer.createReport: start")
+	return <-result
+}
+
+// RunNow queues a run of the schedule outside of its timetable and returns the queued run
+// straight away, so its progress can be followed through the run history.
+func (re *ReportEmailer) RunNow(schedule datasource.Schedule, authConfig *auth.AuthConfig, datasourceID int, em Emailer, options RunOptions) (*datasource.ScheduleRun, error) {
+	queued, err := re.enqueue(job{schedule: schedule, authConfig: authConfig, datasourceID: datasourceID, emailer: em, options: options})
+	if err != nil {
+		log.DefaultLogger.Error("ReportEmailer.RunNow: enqueue: " + err.Error())
+		return nil, err
+	}
+
+	return queued, nil
+}
+
+// enqueue records a queued run for the job and adds the job to the queue, returning the run as
+// it was recorded.
+func (re *ReportEmailer) enqueue(request job) (*datasource.ScheduleRun, error) {
+	var queued datasource.ScheduleRun
+	schedule, options := request.schedule, request.options
+	err := re.queue.Enqueue(schedule.ID, func() (*job, error) {
+		newRun := datasource.ScheduleRun{ScheduleID: schedule.ID, Status: datasource.ScheduleRunStatusQueued}
+
+		// a scheduled run retrying a report time skips whoever an earlier attempt reached
+		if request.scheduled {
+			previous, err := re.datasource.ScheduledRuns(schedule.ID, schedule.NextReportTime)
+			if err != nil {
+				return nil, err
+			}
+
+			newRun.ScheduledTime = schedule.NextReportTime
+			newRun.Attempt = 1
+			for _, run := range previous {
+				if run.Status == datasource.ScheduleRunStatusBlocked {
+					continue
+				}
+				newRun.Attempt++
+				options.delivered = append(options.delivered, run.DeliveredTo...)
+			}
 
-	emails := make(map[string][]string)
-	panels := make(map[string][]api.TablePanel)
+			options.reference = time.Unix(int64(schedule.NextReportTime), 0).In(schedule.Location())
+		}
+
+		run, err := re.datasource.CreateScheduleRun(newRun)
+		if err != nil {
+			return nil, err
+		}
+
+		queued = *run
+
+		j := request
+		j.run, j.options = run, options
+		return &j, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &queued, nil
+}
+
+func (re *ReportEmailer) processJob(j *job) {
+	err := re.executeRun(j.run, j.schedule, j.authConfig, j.datasourceID, j.emailer, j.options)
+	if err != nil {
+		log.DefaultLogger.Error(fmt.Sprintf("ReportEmailer.processJob: schedule %s: %s", j.schedule.Name, err.Error()))
+		bugsnag.Notify(err)
+	}
+
+	// manual runs leave the schedule where it is, but not their report files
+	re.removeReportFiles(j.schedule)
+	if j.scheduled {
+		re.cleanup(j.schedule, j.run)
+	}
+
+	if j.result != nil {
+		j.result <- err
+	}
+}
+
+func (re *ReportEmailer) executeRun(run *datasource.ScheduleRun, schedule datasource.Schedule, authConfig *auth.AuthConfig, datasourceID int, em Emailer, options RunOptions) error {
+	run.Start()
+	if err := re.datasource.UpdateScheduleRun(*run); err != nil {
+		log.DefaultLogger.Error("ReportEmailer.executeRun: UpdateScheduleRun: " + err.Error())
+	}
+
+	err := re.createReport(schedule, authConfig, datasourceID, em, run, options)
+
+	run.Finish(err)
+	if updateErr := re.datasource.UpdateScheduleRun(*run); updateErr != nil {
+		log.DefaultLogger.Error("ReportEmailer.executeRun: UpdateScheduleRun: " + updateErr.Error())
+	}
+
+	return err
+}
+
+// recipients picks who a run is sent to from the email addresses of the report group's members.
+func (options RunOptions) recipients(groupEmails []string) ([]string, error) {
+	if options.OnlyMe {
+		if options.RequesterEmail == "" {
+			return nil, errors.New("the user requesting the run has no email address")
+		}
+		return []string{options.RequesterEmail}, nil
+	}
+
+	if len(options.Recipients) == 0 {
+		return options.undelivered(groupEmails), nil
+	}
+
+	var recipients, notMembers []string
+	for _, recipient := range options.Recipients {
+		if containsFold(groupEmails, recipient) {
+			recipients = append(recipients, recipient)
+		} else {
+			notMembers = append(notMembers, recipient)
+		}
+	}
+
+	if len(notMembers) > 0 {
+		return nil, fmt.Errorf("not members of the report group: %s", strings.Join(notMembers, ", "))
+	}
+
+	return options.undelivered(recipients), nil
+}
+
+// undelivered leaves out the addresses an earlier attempt already delivered the report to.
+func (options RunOptions) undelivered(emails []string) []string {
+	remaining := []string{}
+	for _, email := range emails {
+		if !containsFold(options.delivered, email) {
+			remaining = append(remaining, email)
+		}
+	}
+	return remaining
+}
+
+func containsFold(emails []string, email string) bool {
+	for _, candidate := range emails {
+		if strings.EqualFold(candidate, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// createReport writes and sends a single run of the schedule. Each panel is loaded on its own:
+// a panel which cannot be loaded is recorded on the run and left out of the report, and the
+// report is still sent as long as at least one panel could be loaded.
+func (re *ReportEmailer) createReport(schedule datasource.Schedule, authConfig *auth.AuthConfig, datasourceID int, em Emailer, run *datasource.ScheduleRun, options RunOptions) error {
+
+	log.DefaultLogger.Debug("ReportEmailer.createReport: start")
 
 	reportGroup, err := re.datasource.ReportGroupFromSchedule(schedule)
 	if err != nil {
@@ -104,7 +377,11 @@ func (re *ReportEmailer) CreateReport(schedule datasource.Schedule, authConfig *
 		log.DefaultLogger.Debug("ReportEmailer.createReport: GetEmails:", emailsFromUsers)
 	}
 
-	emails[schedule.ID] = emailsFromUsers
+	recipients, err := options.recipients(emailsFromUsers)
+	if err != nil {
+		log.DefaultLogger.Error("ReportEmailer.createReport: recipients: " + err.Error())
+		return err
+	}
 
 	reportContent, err := re.datasource.GetReportContent(schedule.ID)
 	if err != nil {
@@ -114,76 +391,118 @@ func (re *ReportEmailer) CreateReport(schedule datasource.Schedule, authConfig *
 		log.DefaultLogger.Debug("ReportEmailer.createReport: GetReportContent:", reportContent)
 	}
 
-	panels[schedule.ID] = []api.TablePanel{}
-
-	for _, content := range reportContent {
-		lookback := content.Lookback
-		to := "now"
-		from := lookback
+	if len(reportContent) == 0 {
+		log.DefaultLogger.Info("ReportEmailer.createReport - no panels! quitting report as nothing to do")
+		return nil
+	}
 
-		dashboard, err := api.NewDashboard(authConfig, content.DashboardID, from, to, datasourceID)
+	format, err := NewReportFormat(schedule.Format)
+	if err != nil {
+		log.DefaultLogger.Error("ReportEmailer.createReport: NewReportFormat: " + err.Error())
+		return err
+	}
 
+	panels := []api.TablePanel{}
+	for _, content := range reportContent {
+		panel, err := loadPanel(authConfig, datasourceID, content, schedule, options.reference)
 		if err != nil {
-			log.DefaultLogger.Error("ReportEmailer.createReport: NewDashboard: " + err.Error())
-			return err
-		} else {
-			log.DefaultLogger.Debug("ReportEmailer.createReport: NewDashboard:", dashboard)
-		}
-
-		panel := dashboard.Panel(content.PanelID)
-		if panel != nil {
-			panel.PrepSql(dashboard.Variables, content.Variables)
-			panels[schedule.ID] = append(panels[schedule.ID], *panel)
+			log.DefaultLogger.Error(fmt.Sprintf("ReportEmailer.createReport: schedule %s: panel %d: %s", schedule.Name, content.PanelID, err.Error()))
+			bugsnag.Notify(err)
+			run.PanelRowCounts = append(run.PanelRowCounts, datasource.PanelRowCount{PanelID: content.PanelID, Error: err.Error()})
+			continue
 		}
+		panels = append(panels, *panel)
 	}
 
 	if len(panels) == 0 {
-		log.DefaultLogger.Info("ReportEmailer.createReports - no panels! quitting report as nothing to do")
-		return nil
+		return errors.New("none of the panels in the report could be loaded")
 	}
 
-	templatePath := GetFilePath("template")
+	templatePath := TemplatePath(schedule.TemplateID)
 	log.DefaultLogger.Debug("ReportEmailer.createReport: templatePath:", templatePath)
 	reporter := NewReporter(templatePath)
 
 	log.DefaultLogger.Debug("ReportEmailer.createReport: panels being used: ", panels)
-	for scheduleID, reportSheetPanels := range panels {
-		schedule, err := re.datasource.GetSchedule(scheduleID)
+	report := reporter.CreateNewReport(schedule.ID, schedule.Name)
+	report.SetSheets(panels)
+	report.SetLocation(schedule.Location())
+	report.SetFormat(format)
+	report.SetDetails(ReportDetails{ScheduleName: schedule.Name, ScheduleDescription: schedule.Description, ReportGroupName: reportGroup.Name})
+	report.SetSummarySheet(schedule.SummarySheet)
+	report.SetLayout(schedule.Layout)
+	err = report.Write(*authConfig)
+	run.PanelRowCounts = append(run.PanelRowCounts, report.RowCounts()...)
+	if err != nil {
+		log.DefaultLogger.Error("ReportEmailer.createReport: report.Write: " + err.Error())
+		return err
+	}
 
-		if err != nil {
-			log.DefaultLogger.Error("ReportEmailer: GetSchedule: Could not create report to send.", err.Error())
-			bugsnag.Notify(err)
-		} else {
-			log.DefaultLogger.Debug(fmt.Sprintf("ReportEmailer.createReport: schedule: %s", schedule.Name))
-			report := reporter.CreateNewReport(scheduleID, schedule.Name)
-			report.SetSheets(reportSheetPanels)
-			err := report.Write(*authConfig)
-			if err != nil {
-				log.DefaultLogger.Error("ReportEmailer.createReports: report.Write: " + err.Error())
-				return err
-			}
-		}
+	if report.FailedSheets() == len(panels) {
+		return errors.New("could not get the data for any of the panels in the report")
 	}
 
-	for scheduleID, recipientEmails := range emails {
-		schedule, err := re.datasource.GetSchedule(scheduleID)
+	body := schedule.Description
+	if schedule.InlineRows > 0 {
+		body, err = report.HtmlSummary(schedule.Description, schedule.InlineRows)
 		if err != nil {
-			log.DefaultLogger.Error("ReportEmailer: GetSchedule: Could not create report to send.", err.Error())
-			bugsnag.Notify(err)
-		} else {
-			attachmentPath := GetFilePath(schedule.Name)
-			log.DefaultLogger.Debug("ReportEmailer.createReport: attachmentPath:", attachmentPath)
-			em.BulkCreateAndSend(attachmentPath, recipientEmails, schedule.Name, schedule.Description)
+			log.DefaultLogger.Error("ReportEmailer.createReport: HtmlSummary: " + err.Error())
+			return err
 		}
 	}
 
+	attachmentPath := report.Path()
+	log.DefaultLogger.Debug("ReportEmailer.createReport: attachmentPath:", attachmentPath)
+	delivered := em.BulkCreateAndSend(attachmentPath, recipients, schedule.Name, body)
+	run.RecipientsAttempted += len(recipients)
+	run.RecipientsSucceeded += len(delivered)
+	run.DeliveredTo = append(run.DeliveredTo, delivered...)
+
 	return nil
-	// re.cleanup(schedules)
+}
+
+// loadPanel finds the panel of the report content on its dashboard and prepares its query.
+func loadPanel(authConfig *auth.AuthConfig, datasourceID int, content datasource.ReportContent, schedule datasource.Schedule, reference time.Time) (*api.TablePanel, error) {
+	from, to, err := timeRange(content.Lookback, schedule, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard, err := api.NewDashboard(authConfig, content.DashboardID, from, to, datasourceID)
+	if err != nil {
+		return nil, err
+	}
+	log.DefaultLogger.Debug("ReportEmailer.loadPanel: NewDashboard:", dashboard)
+
+	panel := dashboard.Panel(content.PanelID)
+	if panel == nil {
+		return nil, fmt.Errorf("panel with ID %d cannot be found on dashboard %s", content.PanelID, content.DashboardID)
+	}
+	panel.PrepSql(dashboard.Variables, content.Variables)
+
+	return panel, nil
+}
+
+// timeRange works out the from and to times a panel's lookback covers. Calendar-aligned
+// lookbacks are worked out from the report time of a scheduled run, or from now for a run
+// requested by hand, in the schedule's time zone. Rolling lookbacks end now, unless the run is
+// catching up on a missed report time, when they end at that time.
+func timeRange(lookback string, schedule datasource.Schedule, reference time.Time) (string, string, error) {
+	if api.IsCalendarLookback(lookback) {
+		if reference.IsZero() {
+			reference = time.Now()
+		}
+		return api.CalendarTimeRange(lookback, reference.In(schedule.Location()), schedule.FiscalYearStartMonth)
+	}
+
+	if reference.IsZero() || schedule.CatchUpPolicy != datasource.CatchUpPolicyAll {
+		return lookback, "now", nil
+	}
+
+	return api.ResolveTimeRange(lookback, "now", reference)
 }
 
 func (re *ReportEmailer) CreateReports() {
 	log.DefaultLogger.Info("Creating Reports...")
-	re.inProgress = true
 
 	authConfig, emailConfig, datasourceID, err := re.configs()
 	if err != nil {
@@ -209,91 +528,21 @@ func (re *ReportEmailer) CreateReports() {
 		return
 	}
 
-	emails := make(map[string][]string)
-	panels := make(map[string][]api.TablePanel)
 	for _, schedule := range schedules {
-		reportGroup, err := re.datasource.ReportGroupFromSchedule(schedule)
-		if err != nil {
-			log.DefaultLogger.Error("ReportEmailer.createReports: ReportGroupFromSchedule: " + err.Error())
-			return
-		}
-
-		userIDs, err := re.datasource.GroupMemberUserIDs(reportGroup)
+		waiting, err := re.waitForUpstream(schedule)
 		if err != nil {
-			log.DefaultLogger.Error("ReportEmailer.createReports: GroupMemberUserIDs: " + err.Error())
-			return
-		}
-
-		emailsFromUsers, err := api.GetEmails(*authConfig, userIDs, datasourceID)
-		if err != nil {
-			log.DefaultLogger.Error("ReportEmailer.createReports: emailsFromUsers: " + err.Error())
-			return
-		}
-
-		emails[schedule.ID] = emailsFromUsers
-
-		reportContent, err := re.datasource.GetReportContent(schedule.ID)
-		if err != nil {
-			log.DefaultLogger.Error("ReportEmailer.createReports: GetReportContent: " + err.Error())
-			return
-		}
-
-		panels[schedule.ID] = []api.TablePanel{}
-
-		for _, content := range reportContent {
-			lookback := content.Lookback
-			to := "now"
-			from := lookback
-
-			dashboard, err := api.NewDashboard(authConfig, content.DashboardID, from, to, datasourceID)
-
-			if err != nil {
-				log.DefaultLogger.Error("ReportEmailer.createReports: NewDashboard: " + err.Error())
-				return
-			}
-
-			panel := dashboard.Panel(content.PanelID)
-
-			if panel != nil {
-				panel.PrepSql(dashboard.Variables, content.Variables)
-				panels[schedule.ID] = append(panels[schedule.ID], *panel)
-			}
-
+			log.DefaultLogger.Error("ReportEmailer.createReports: waitForUpstream: " + err.Error())
+			continue
 		}
-	}
-
-	templatePath := GetFilePath("template")
-	reporter := NewReporter(templatePath)
-
-	for scheduleID, reportSheetPanels := range panels {
-		schedule, err := re.datasource.GetSchedule(scheduleID)
-		if err != nil {
-			log.DefaultLogger.Error("ReportEmailer: GetSchedule: Could not create report to send.", err.Error())
-			bugsnag.Notify(err)
-		} else {
-			report := reporter.CreateNewReport(scheduleID, schedule.Name)
-
-			report.SetSheets(reportSheetPanels)
-			err := report.Write(*authConfig)
-			if err != nil {
-				log.DefaultLogger.Error("ReportEmailer.createReports: report.Write: " + err.Error())
-				return
-			}
+		if waiting {
+			continue
 		}
 
-	}
-
-	for scheduleID, recipientEmails := range emails {
-		schedule, err := re.datasource.GetSchedule(scheduleID)
-		if err != nil {
-			log.DefaultLogger.Error("ReportEmailer: GetSchedule: Could not create report to send.", err.Error())
-			bugsnag.Notify(err)
-		} else {
-			attachmentPath := GetFilePath(schedule.Name)
-			em.BulkCreateAndSend(attachmentPath, recipientEmails, schedule.Name, schedule.Description)
+		_, err = re.enqueue(job{schedule: schedule, authConfig: authConfig, datasourceID: datasourceID, emailer: *em, scheduled: true})
+		if errors.Is(err, ErrScheduleInProgress) {
+			log.DefaultLogger.Info(fmt.Sprintf("ReportEmailer.createReports: %s is already queued or running", schedule.Name))
+		} else if err != nil {
+			log.DefaultLogger.Error("ReportEmailer.createReports: enqueue: " + err.Error())
 		}
 	}
-
-	re.cleanup(schedules)
-
 }
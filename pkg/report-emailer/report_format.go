@@ -0,0 +1,166 @@
+package reportEmailer
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"excel-report-email-scheduler/pkg/datasource"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReportFormat writes the sheets of a report, once their data has been fetched, to a file.
+type ReportFormat interface {
+	// Extension returns the extension, without a dot, of the file the report is written to
+	Extension(report *Report) string
+	Write(report *Report, path string) error
+}
+
+// NewReportFormat returns the format with the given name. An empty name is the default xlsx format.
+func NewReportFormat(name string) (ReportFormat, error) {
+	switch name {
+	case "", datasource.ReportFormatXlsx:
+		return xlsxFormat{}, nil
+	case datasource.ReportFormatCsv:
+		return csvFormat{}, nil
+	case datasource.ReportFormatPdf:
+		return pdfFormat{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown report format: %s", name)
+}
+
+type xlsxFormat struct{}
+
+func (xlsxFormat) Extension(report *Report) string {
+	return datasource.ReportFormatXlsx
+}
+
+func (xlsxFormat) Write(report *Report, path string) error {
+	return report.writeXlsx(path)
+}
+
+type pdfFormat struct{}
+
+func (pdfFormat) Extension(report *Report) string {
+	return datasource.ReportFormatPdf
+}
+
+func (pdfFormat) Write(report *Report, path string) error {
+	return report.writePdf(path)
+}
+
+// csvFormat writes each sheet to a CSV file. A report with more than one sheet is sent as a
+// zip file holding a CSV file for each sheet.
+type csvFormat struct{}
+
+func (csvFormat) Extension(report *Report) string {
+	if len(report.sheets) > 1 {
+		return "zip"
+	}
+	return datasource.ReportFormatCsv
+}
+
+func (csvFormat) Write(report *Report, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if len(report.sheets) <= 1 {
+		if err = report.writeCsv(file, 0); err != nil {
+			return err
+		}
+		return file.Close()
+	}
+
+	archive := zip.NewWriter(file)
+	used := make(map[string]bool)
+	for i, sheet := range report.sheets {
+		entry, err := archive.Create(csvFileName(sheet.Title, used))
+		if err != nil {
+			return err
+		}
+
+		if err = report.writeCsv(entry, i); err != nil {
+			return err
+		}
+	}
+
+	if err = archive.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// writeCsv writes the headers and rows of a sheet as CSV.
+func (r *Report) writeCsv(w io.Writer, sheetIndex int) error {
+	writer := csv.NewWriter(w)
+	if sheetIndex < len(r.sheets) {
+		sheet := r.sheets[sheetIndex]
+
+		if len(sheet.Columns) > 0 {
+			headers := make([]string, len(sheet.Columns))
+			for i, column := range sheet.Columns {
+				headers[i] = column.Text
+			}
+			if err := writer.Write(headers); err != nil {
+				return err
+			}
+		}
+
+		for _, row := range sheet.Rows {
+			record := make([]string, len(row))
+			for i, value := range row {
+				record[i] = csvValue(value)
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+
+	if date, ok := toDateString(value); ok {
+		return date
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// csvFileName names the CSV file of a sheet in a zip file after its title, numbering titles
+// which are already used.
+func csvFileName(title string, used map[string]bool) string {
+	name := strings.NewReplacer("/", "-", "\\", "-", ":", "-").Replace(strings.TrimSpace(title))
+	if name == "" {
+		name = "panel"
+	}
+
+	fileName := name + ".csv"
+	for i := 2; used[strings.ToLower(fileName)]; i++ {
+		fileName = fmt.Sprintf("%s (%d).csv", name, i)
+	}
+	used[strings.ToLower(fileName)] = true
+
+	return fileName
+}
@@ -0,0 +1,128 @@
+package reportEmailer
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"excel-report-email-scheduler/pkg/api"
+)
+
+func TestWriteCsv(t *testing.T) {
+	r := &Report{sheets: []api.TablePanel{{
+		Title:   "Stock",
+		Columns: []api.Column{{Text: "Item"}, {Text: "Notes, if any"}, {Text: "Quantity"}, {Text: "In stock"}},
+		Rows: [][]interface{}{
+			{"Amoxicillin", `Ask for "Amoxil"`, 10.5, true},
+			{"Zinc", "First line\nsecond line", nil, false},
+			{"Paracetamol", "", 3.0, nil},
+			{"ORS", "2024-05-15T10:30:00Z"},
+		},
+	}}}
+
+	var buffer bytes.Buffer
+	if err := r.writeCsv(&buffer, 0); err != nil {
+		t.Fatalf("writeCsv returned an error: %v", err)
+	}
+
+	want := "Item,\"Notes, if any\",Quantity,In stock\n" +
+		"Amoxicillin,\"Ask for \"\"Amoxil\"\"\",10.5,true\n" +
+		"Zinc,\"First line\nsecond line\",,false\n" +
+		"Paracetamol,,3,\n" +
+		"ORS,2024/05/15\n"
+	if got := buffer.String(); got != want {
+		t.Errorf("writeCsv wrote\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCsvFormat(t *testing.T) {
+	stock := api.TablePanel{Title: "Stock", Columns: []api.Column{{Text: "Item"}}, Rows: [][]interface{}{{"Zinc"}}}
+	orders := api.TablePanel{Title: "Orders", Columns: []api.Column{{Text: "Order"}}, Rows: [][]interface{}{{"A1"}}}
+
+	t.Run("one panel is a CSV file", func(t *testing.T) {
+		r := &Report{sheets: []api.TablePanel{stock}}
+		if extension := (csvFormat{}).Extension(r); extension != "csv" {
+			t.Errorf("Extension = %q, want csv", extension)
+		}
+
+		path := filepath.Join(t.TempDir(), "report.csv")
+		if err := (csvFormat{}).Write(r, path); err != nil {
+			t.Fatalf("Write returned an error: %v", err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "Item\nZinc\n"; string(content) != want {
+			t.Errorf("the report is %q, want %q", content, want)
+		}
+	})
+
+	t.Run("several panels are zipped", func(t *testing.T) {
+		r := &Report{sheets: []api.TablePanel{stock, orders, stock}}
+		if extension := (csvFormat{}).Extension(r); extension != "zip" {
+			t.Errorf("Extension = %q, want zip", extension)
+		}
+
+		path := filepath.Join(t.TempDir(), "report.zip")
+		if err := (csvFormat{}).Write(r, path); err != nil {
+			t.Fatalf("Write returned an error: %v", err)
+		}
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatalf("the report is not a zip file: %v", err)
+		}
+		defer archive.Close()
+
+		got := map[string]string{}
+		names := []string{}
+		for _, entry := range archive.File {
+			reader, err := entry.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, entry.Name)
+			got[entry.Name] = string(content)
+		}
+
+		if want := []string{"Stock.csv", "Orders.csv", "Stock (2).csv"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("the zip file holds %q, want %q", names, want)
+		}
+		want := map[string]string{"Stock.csv": "Item\nZinc\n", "Orders.csv": "Order\nA1\n", "Stock (2).csv": "Item\nZinc\n"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("the zip file holds %q, want %q", got, want)
+		}
+	})
+}
+
+func TestCsvFileName(t *testing.T) {
+	used := make(map[string]bool)
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Stock", want: "Stock.csv"},
+		{title: "stock", want: "stock (2).csv"},
+		{title: "Stock", want: "Stock (3).csv"},
+		{title: "Stock (2)", want: "Stock (2) (2).csv"},
+		{title: " Stock by store/region: North\\South ", want: "Stock by store-region- North-South.csv"},
+		{title: "", want: "panel.csv"},
+		{title: "  ", want: "panel (2).csv"},
+	}
+
+	// each title is named in turn, as the panels of one report are
+	for _, test := range tests {
+		if got := csvFileName(test.title, used); got != test.want {
+			t.Errorf("csvFileName(%q) = %q, want %q", test.title, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package reportEmailer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// MAX_SHEET_NAME_LENGTH is the longest name Excel allows for a sheet, counted in UTF-16 units.
+const MAX_SHEET_NAME_LENGTH = 31
+
+// INVALID_SHEET_NAME_REG matches the characters Excel does not allow in the name of a sheet.
+var INVALID_SHEET_NAME_REG = regexp.MustCompile(`[\\/?*\[\]:\x00-\x1f]`)
+
+// RESERVED_SHEET_NAMES are names Excel or the report itself uses.
+var RESERVED_SHEET_NAMES = []string{"History", TEMPLATE_SHEET}
+
+// truncateSheetName shortens a name to fit length UTF-16 units, without splitting a character
+// or leaving an apostrophe at the end.
+func truncateSheetName(name string, length int) string {
+	units := 0
+	for i, character := range name {
+		units += utf16.RuneLen(character)
+		if units > length {
+			return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(name[:i]), "'"))
+		}
+	}
+	return name
+}
+
+// cleanSheetName replaces the characters Excel does not allow in a sheet's name, which also
+// cannot start or end with an apostrophe.
+func cleanSheetName(title string) string {
+	name := INVALID_SHEET_NAME_REG.ReplaceAllString(title, "_")
+	name = strings.TrimSpace(strings.Trim(strings.TrimSpace(name), "'"))
+	if name == "" {
+		name = "Sheet"
+	}
+	return name
+}
+
+// sheetCellRef returns a reference to a cell of a sheet, such as 'Stock on hand'!A1.
+func sheetCellRef(sheetName string, cell string) string {
+	return "'" + strings.ReplaceAll(sheetName, "'", "''") + "'!" + cell
+}
+
+// sheetNames returns the name of the sheet for each panel. The titles of the panels themselves
+// are left alone, so {{title}} still prints the panel's title.
+func (r *Report) sheetNames() []string {
+	titles := make([]string, len(r.sheets))
+	for i, sheet := range r.sheets {
+		titles[i] = sheet.Title
+	}
+	return r.uniqueSheetNames(titles)
+}
+
+// takenSheetNames returns the lower-cased names a new sheet cannot have: the reserved names
+// and the names of the sheets already in the workbook, such as other sheets of an uploaded
+// template.
+func (r *Report) takenSheetNames() map[string]bool {
+	taken := make(map[string]bool)
+	names := RESERVED_SHEET_NAMES
+	if r.file != nil {
+		names = append(r.file.GetSheetList(), names...)
+	}
+	for _, name := range names {
+		taken[strings.ToLower(name)] = true
+	}
+	return taken
+}
+
+// numberedSheetName returns base shortened so Excel accepts it, numbered as "Stock (2)" when
+// it is already used, ignoring case as Excel does.
+func numberedSheetName(base string, used map[string]bool) string {
+	name := truncateSheetName(base, MAX_SHEET_NAME_LENGTH)
+	for number := 2; used[strings.ToLower(name)]; number++ {
+		suffix := fmt.Sprintf(" (%d)", number)
+		name = truncateSheetName(base, MAX_SHEET_NAME_LENGTH-len(suffix)) + suffix
+	}
+	return name
+}
+
+// summarySheetName returns the name of the summary sheet, which is numbered when the
+// template already has a sheet named SUMMARY_SHEET.
+func (r *Report) summarySheetName() string {
+	return numberedSheetName(SUMMARY_SHEET, r.takenSheetNames())
+}
+
+// uniqueSheetNames returns a name for a sheet with each of the titles. The names are the
+// titles cleaned and shortened so Excel accepts them, and numbered when they would be the
+// same as an earlier name, a reserved one or a sheet already in the workbook.
+func (r *Report) uniqueSheetNames(titles []string) []string {
+	used := r.takenSheetNames()
+	if r.summarySheet {
+		used[strings.ToLower(r.summarySheetName())] = true
+	}
+
+	names := make([]string, len(titles))
+	for i, title := range titles {
+		name := numberedSheetName(cleanSheetName(title), used)
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+
+	return names
+}
@@ -0,0 +1,168 @@
+package reportEmailer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestCleanSheetName(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Stock on hand", want: "Stock on hand"},
+		{title: "a/b?c*[d]:e", want: "a_b_c__d__e"},
+		{title: `back\slash`, want: "back_slash"},
+		{title: "tab\there", want: "tab_here"},
+		{title: "'quoted'", want: "quoted"},
+		{title: "  ' padded '  ", want: "padded"},
+		{title: "", want: "Sheet"},
+		{title: "''", want: "Sheet"},
+		{title: "ສາງສິນຄ້າ", want: "ສາງສິນຄ້າ"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			if got := cleanSheetName(test.title); got != test.want {
+				t.Errorf("cleanSheetName(%q) = %q, want %q", test.title, got, test.want)
+			}
+		})
+	}
+}
+
+func TestTruncateSheetName(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		want   string
+	}{
+		{name: "Stock", length: 31, want: "Stock"},
+		{name: "Stock on hand by store and item for the last month", length: 31, want: "Stock on hand by store and item"},
+		{name: "Stock on hand by store and item for the last month", length: 27, want: "Stock on hand by store and"},
+		{name: "Don't stop", length: 4, want: "Don"},
+		// each emoji takes two UTF-16 units, so only 15 fit in 31
+		{name: strings.Repeat("📦", 16), length: 31, want: strings.Repeat("📦", 15)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := truncateSheetName(test.name, test.length); got != test.want {
+				t.Errorf("truncateSheetName(%q, %d) = %q, want %q", test.name, test.length, got, test.want)
+			}
+		})
+	}
+}
+
+func TestUniqueSheetNames(t *testing.T) {
+	long := "Stock on hand by store and item for the last month"
+
+	tests := []struct {
+		name         string
+		summarySheet bool
+		titles       []string
+		want         []string
+	}{
+		{
+			name:   "distinct titles are kept",
+			titles: []string{"Stock", "Sales"},
+			want:   []string{"Stock", "Sales"},
+		},
+		{
+			name:   "duplicates are numbered, ignoring case",
+			titles: []string{"stock", "Stock", "STOCK"},
+			want:   []string{"stock", "Stock (2)", "STOCK (3)"},
+		},
+		{
+			name:   "long duplicates are shortened to fit their number",
+			titles: []string{long, long},
+			want:   []string{"Stock on hand by store and item", "Stock on hand by store and (2)"},
+		},
+		{
+			name:   "titles which clean to the same name are numbered",
+			titles: []string{"a/b", "a?b"},
+			want:   []string{"a_b", "a_b (2)"},
+		},
+		{
+			name:   "reserved names are numbered",
+			titles: []string{"History", TEMPLATE_SHEET},
+			want:   []string{"History (2)", TEMPLATE_SHEET + " (2)"},
+		},
+		{
+			name:         "the summary sheet's name is reserved when it is added",
+			summarySheet: true,
+			titles:       []string{SUMMARY_SHEET},
+			want:         []string{SUMMARY_SHEET + " (2)"},
+		},
+		{
+			name:   "the summary sheet's name is free without it",
+			titles: []string{SUMMARY_SHEET},
+			want:   []string{SUMMARY_SHEET},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Report{summarySheet: test.summarySheet}
+			got := r.uniqueSheetNames(test.titles)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("uniqueSheetNames(%q) = %q, want %q", test.titles, got, test.want)
+			}
+			for _, name := range got {
+				if units := len(utf16.Encode([]rune(name))); units > MAX_SHEET_NAME_LENGTH {
+					t.Errorf("sheet name %q is %d UTF-16 units long", name, units)
+				}
+			}
+		})
+	}
+}
+
+func TestUniqueSheetNamesAvoidTemplateSheets(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+	if _, err := file.NewSheet("Notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Report{file: file}
+	got := r.uniqueSheetNames([]string{"notes", "Sheet1", "Stock"})
+	if want := []string{"notes (2)", "Sheet1 (2)", "Stock"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueSheetNames = %q, want %q", got, want)
+	}
+}
+
+func TestSummarySheetKeepsTemplateSheet(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+	if _, err := file.NewSheet(SUMMARY_SHEET); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.SetCellValue(SUMMARY_SHEET, "A1", "Notes for the reader"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Report{file: file, location: time.UTC, summarySheet: true}
+	if got := r.uniqueSheetNames([]string{SUMMARY_SHEET + " (2)"}); got[0] != SUMMARY_SHEET+" (2) (2)" {
+		t.Errorf("a panel was named %q, the same as the summary sheet", got[0])
+	}
+	if err := r.writeSummarySheet(nil); err != nil {
+		t.Fatalf("writeSummarySheet returned an error: %v", err)
+	}
+
+	if value, _ := file.GetCellValue(SUMMARY_SHEET, "A1"); value != "Notes for the reader" {
+		t.Errorf("the template's %s sheet was overwritten, A1 = %q", SUMMARY_SHEET, value)
+	}
+	if index, _ := file.GetSheetIndex(SUMMARY_SHEET + " (2)"); index < 0 {
+		t.Errorf("the summary sheet was not added as %q", SUMMARY_SHEET+" (2)")
+	}
+}
+
+func TestSheetCellRef(t *testing.T) {
+	if got, want := sheetCellRef("Bob's stock", "A1"), "'Bob''s stock'!A1"; got != want {
+		t.Errorf("sheetCellRef = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,391 @@
+package reportEmailer
+
+import (
+	"errors"
+	"excel-report-email-scheduler/pkg/api"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	// STACKED_PANEL_SPACING is how many empty rows are left between the panels of a stacked sheet.
+	STACKED_PANEL_SPACING = 2
+	// STACKED_CHART_ROWS is how many rows of the default height a chart covers.
+	STACKED_CHART_ROWS = CHART_HEIGHT / 20
+)
+
+// panelRegion is the rows of the template sheet, from start to end, which are written for each
+// panel of a stacked sheet, along with the rows of its {{headers}} and {{rows}}.
+type panelRegion struct {
+	start      int
+	end        int
+	headersRow int
+	rowsRow    int
+}
+
+// placeholderRow returns the row of the first cell of a sheet which is the placeholder, or 0
+// when there is none.
+func placeholderRow(f *excelize.File, sheetName string, placeholder string, reg bool) (int, error) {
+	refs, err := f.SearchSheet(sheetName, placeholder, reg)
+	if err != nil || len(refs) == 0 {
+		return 0, err
+	}
+
+	_, row, err := excelize.CellNameToCoordinates(refs[0])
+	return row, err
+}
+
+// findPanelRegion finds the rows of a template sheet which are written for each panel of a
+// stacked sheet. They run from the row of {{panel.start}} to the row of {{panel.end}}, or when
+// the template does not mark them, from the {{title}} row above the {{headers}}, if there is
+// one, to the {{rows}} row.
+func findPanelRegion(f *excelize.File, sheetName string) (panelRegion, error) {
+	region := panelRegion{}
+	var err error
+	if region.headersRow, err = placeholderRow(f, sheetName, "{{headers}}", false); err != nil {
+		return region, err
+	}
+	if region.rowsRow, err = placeholderRow(f, sheetName, "{{rows}}", false); err != nil {
+		return region, err
+	}
+	if region.headersRow == 0 || region.rowsRow == 0 {
+		return region, errors.New("the template sheet is missing the placeholders " + strings.Join(REQUIRED_PLACEHOLDERS, ", "))
+	}
+
+	if region.start, err = placeholderRow(f, sheetName, PANEL_START_PLACEHOLDER, false); err != nil {
+		return region, err
+	}
+	if region.end, err = placeholderRow(f, sheetName, PANEL_END_PLACEHOLDER, false); err != nil {
+		return region, err
+	}
+
+	switch {
+	case region.start == 0 && region.end == 0:
+		region.start = int(math.Min(float64(region.headersRow), float64(region.rowsRow)))
+		region.end = int(math.Max(float64(region.headersRow), float64(region.rowsRow)))
+		titleRow, err := placeholderRow(f, sheetName, `^\{\{\s*title\s*\}\}$`, true)
+		if err != nil {
+			return region, err
+		}
+		if titleRow > 0 && titleRow < region.start {
+			region.start = titleRow
+		}
+	case region.start == 0 || region.end == 0:
+		return region, errors.New("the template sheet must mark the rows of each panel with both " + PANEL_START_PLACEHOLDER + " and " + PANEL_END_PLACEHOLDER)
+	}
+
+	for _, row := range []int{region.headersRow, region.rowsRow} {
+		if row < region.start || row > region.end {
+			return region, errors.New("the rows from " + PANEL_START_PLACEHOLDER + " to " + PANEL_END_PLACEHOLDER + " must include the {{headers}} and {{rows}} rows")
+		}
+	}
+
+	return region, nil
+}
+
+// panelBlock is where the copy of the region for a panel starts on a stacked sheet, and how
+// many rows the panel's table adds to it.
+type panelBlock struct {
+	top   int
+	added int
+}
+
+// rows returns the first and last rows of the stacked sheet a row of the region is written to.
+// The {{rows}} row becomes the rows of the panel's table, moving the rows below it down.
+func (block panelBlock) rows(region panelRegion, row int) (int, int) {
+	first := block.top + row - region.start
+	if row > region.rowsRow {
+		first += block.added
+	}
+	if row == region.rowsRow {
+		return first, first + block.added
+	}
+	return first, first
+}
+
+// stackedLayout is where each panel goes on a stacked sheet.
+type stackedLayout struct {
+	name   string
+	region panelRegion
+	blocks []panelBlock
+	// footerShift is how far the rows of the template below the region move down
+	footerShift int
+}
+
+// links returns where each panel's block starts, for the contents sheet.
+func (layout stackedLayout) links() []string {
+	links := make([]string, len(layout.blocks))
+	for i, block := range layout.blocks {
+		links[i] = sheetCellRef(layout.name, "A"+strconv.Itoa(block.top))
+	}
+	return links
+}
+
+// move returns the rows of the stacked sheet a row of the template above or below the region
+// is written to.
+func (layout stackedLayout) move(row int) (int, int) {
+	if row > layout.region.end {
+		row += layout.footerShift
+	}
+	return row, row
+}
+
+// planStackedSheet works out where each panel goes when they are stacked on a single sheet,
+// named after the schedule. A copy of the template's region is written for each panel, with a
+// few empty rows between them, and room left beside a chart panel's table for its chart.
+func (r *Report) planStackedSheet() (stackedLayout, error) {
+	region, err := findPanelRegion(r.file, TEMPLATE_SHEET)
+	if err != nil {
+		return stackedLayout{}, err
+	}
+
+	layout := stackedLayout{name: r.uniqueSheetNames([]string{r.title()})[0], region: region}
+	top := region.start
+	for i, panel := range r.sheets {
+		block := panelBlock{top: top}
+		if len(panel.Rows) > 1 {
+			block.added = len(panel.Rows) - 1
+		}
+
+		height := region.end - region.start + 1 + block.added
+		if _, failed := r.sheetErrors[i]; panel.IsChart() && !failed && len(panel.Rows) > 0 {
+			headersRow, _ := block.rows(region, region.headersRow)
+			if chartHeight := headersRow - top + STACKED_CHART_ROWS; chartHeight > height {
+				height = chartHeight
+			}
+		}
+
+		layout.blocks = append(layout.blocks, block)
+		top += height + STACKED_PANEL_SPACING
+	}
+
+	last := top - 1
+	if len(layout.blocks) > 0 {
+		last -= STACKED_PANEL_SPACING
+	}
+	layout.footerShift = last - region.end
+
+	return layout, nil
+}
+
+// reportPanel is what the rows of a stacked sheet outside the panels' region are filled for:
+// the first panel, titled with the schedule's name.
+func (r *Report) reportPanel() api.TablePanel {
+	panel := api.TablePanel{}
+	if len(r.sheets) > 0 {
+		panel = r.sheets[0]
+	}
+	panel.Title = r.title()
+	return panel
+}
+
+// moveConditionalFormats returns the parts of the ranges of conditional formats which are
+// within rows first to last of the template, moved to the rows move writes them to.
+func (r *Report) moveConditionalFormats(formats map[string][]excelize.ConditionalFormatOptions, first int, last int, move func(int) (int, int)) (map[string][]excelize.ConditionalFormatOptions, error) {
+	moved := make(map[string][]excelize.ConditionalFormatOptions)
+	for ranges, options := range formats {
+		parts := []string{}
+		for _, cells := range strings.Fields(ranges) {
+			startColumn, start, endColumn, end, err := rangeCoordinates(cells)
+			if err != nil {
+				return nil, err
+			}
+
+			start = int(math.Max(float64(start), float64(first)))
+			end = int(math.Min(float64(end), float64(last)))
+			if start > end {
+				continue
+			}
+			top, _ := move(start)
+			_, bottom := move(end)
+			if top > excelize.TotalRows {
+				continue
+			}
+			bottom = int(math.Min(float64(bottom), excelize.TotalRows))
+			parts = append(parts, r.createCellRef(startColumn-1, top)+":"+r.createCellRef(endColumn-1, bottom))
+		}
+
+		if len(parts) > 0 {
+			moved[strings.Join(parts, " ")] = options
+		}
+	}
+
+	return moved, nil
+}
+
+// writeStackedSheet fills a single copy of the template sheet with every panel of the report,
+// one below another, as planned by planStackedSheet. Each panel gets its own copy of the
+// template's region, with its placeholders, merged cells and conditional formats. The rows
+// above the region are written once at the top and the rows below it once after the last
+// panel, filled for the report as a whole.
+func (r *Report) writeStackedSheet(templateIndex int, layout stackedLayout) error {
+	name, region := layout.name, layout.region
+	log.DefaultLogger.Info(fmt.Sprintf("Creating new sheet %s", name))
+	sIdx, err := r.file.NewSheet(name)
+	if err != nil {
+		log.DefaultLogger.Error("writeStackedSheet: NewSheet: " + err.Error())
+		return err
+	}
+	if err := r.file.CopySheet(templateIndex, sIdx); err != nil {
+		log.DefaultLogger.Error("writeStackedSheet: copySheet: " + err.Error())
+		return err
+	}
+
+	template, err := r.readTemplateRows(name)
+	if err != nil {
+		return err
+	}
+	merges, err := r.file.GetMergeCells(name)
+	if err != nil {
+		return err
+	}
+
+	// the template's conditional formats are added again for each panel
+	formats, err := r.file.GetConditionalFormats(name)
+	if err != nil {
+		return err
+	}
+	for ranges := range formats {
+		if err := r.file.UnsetConditionalFormat(name, ranges); err != nil {
+			return err
+		}
+	}
+
+	tables := make([][]api.Column, len(r.sheets))
+	headerStyles := make([]int, len(r.sheets))
+	rowStyles := make([][]int, len(r.sheets))
+	widths := []float64{}
+	for i, panel := range r.sheets {
+		tables[i] = panel.Columns
+		if panel.IsChart() {
+			tables[i] = chartColumns(panel.Columns)
+		}
+
+		headerStyles[i], rowStyles[i], err = r.tableStyles(name, tables[i], region.headersRow, region.rowsRow)
+		if err != nil {
+			return err
+		}
+
+		for j, width := range columnWidths(tables[i], panel.Rows) {
+			if j < len(widths) {
+				widths[j] = math.Max(widths[j], width)
+			} else {
+				widths = append(widths, width)
+			}
+		}
+	}
+
+	sw, err := r.file.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	if err := r.setColumnWidths(sw, name, widths, template); err != nil {
+		return err
+	}
+
+	report := r.reportPanel()
+	for row := 1; row < region.start; row++ {
+		if err := r.streamTemplateRow(sw, template, row, row, report); err != nil {
+			return err
+		}
+	}
+
+	for i, panel := range r.sheets {
+		block := layout.blocks[i]
+		for row := region.start; row <= region.end; row++ {
+			rowNumber, _ := block.rows(region, row)
+			var err error
+			switch row {
+			case region.headersRow:
+				err = r.streamHeaders(sw, rowNumber, tables[i], headerStyles[i])
+			case region.rowsRow:
+				err = r.streamRows(sw, rowNumber, tables[i], panel.Rows, rowStyles[i])
+			default:
+				err = r.streamTemplateRow(sw, template, row, rowNumber, panel)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for row := region.end + 1; row <= len(template); row++ {
+		rowNumber, _ := layout.move(row)
+		if err := r.streamTemplateRow(sw, template, row, rowNumber, report); err != nil {
+			return err
+		}
+	}
+
+	for _, merge := range merges {
+		startColumn, start, endColumn, end, err := rangeCoordinates(merge.GetStartAxis() + ":" + merge.GetEndAxis())
+		if err != nil {
+			return err
+		}
+
+		moves := []func(int) (int, int){}
+		switch {
+		case end < region.start || start > region.end:
+			moves = append(moves, layout.move)
+		case region.start <= start && end <= region.end:
+			if (start <= region.headersRow && region.headersRow <= end) || (start <= region.rowsRow && region.rowsRow <= end) {
+				continue
+			}
+			for _, block := range layout.blocks {
+				block := block
+				moves = append(moves, func(row int) (int, int) { return block.rows(region, row) })
+			}
+		}
+
+		for _, move := range moves {
+			first, _ := move(start)
+			_, last := move(end)
+			if err := sw.MergeCell(r.createCellRef(startColumn-1, first), r.createCellRef(endColumn-1, last)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the worksheet's conditional formats and charts are written when the stream is flushed
+	for i, panel := range r.sheets {
+		block := layout.blocks[i]
+		move := func(row int) (int, int) { return block.rows(region, row) }
+
+		headersRow, _ := move(region.headersRow)
+		rowsRow, _ := move(region.rowsRow)
+		if err := r.setColumnFormats(name, tables[i], panel.Rows, rowsRow); err != nil {
+			return err
+		}
+
+		regionFormats, err := r.moveConditionalFormats(formats, region.start, region.end, move)
+		if err != nil {
+			return err
+		}
+		if err := r.addConditionalFormats(name, regionFormats); err != nil {
+			return err
+		}
+
+		if panel.IsChart() {
+			if err := r.addChart(name, panel, tables[i], headersRow, rowsRow); err != nil {
+				log.DefaultLogger.Error("writeStackedSheet: addChart: " + err.Error())
+				return err
+			}
+		}
+	}
+
+	for _, rows := range [][]int{{1, region.start - 1}, {region.end + 1, excelize.TotalRows}} {
+		outsideFormats, err := r.moveConditionalFormats(formats, rows[0], rows[1], layout.move)
+		if err != nil {
+			return err
+		}
+		if err := r.addConditionalFormats(name, outsideFormats); err != nil {
+			return err
+		}
+	}
+
+	return sw.Flush()
+}
@@ -0,0 +1,167 @@
+package reportEmailer
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"excel-report-email-scheduler/pkg/api"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// openWorkbook opens a workbook made by workbook.
+func openWorkbook(t *testing.T, content []byte) *excelize.File {
+	t.Helper()
+	file, err := excelize.OpenReader(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func TestFindPanelRegion(t *testing.T) {
+	tests := []struct {
+		name    string
+		cells   map[string]string
+		want    panelRegion
+		wantErr string
+	}{
+		{
+			name: "marked region",
+			cells: map[string]string{
+				"A1": "Stock report", "A2": "{{panel.start}}", "A3": "{{title}}", "A4": "{{headers}}",
+				"A5": "{{rows}}", "A6": "Total", "B7": "{{panel.end}}", "A8": "Prepared by the pharmacy",
+			},
+			want: panelRegion{start: 2, end: 7, headersRow: 4, rowsRow: 5},
+		},
+		{
+			name:  "title through rows",
+			cells: map[string]string{"A1": "Stock report", "A2": "{{title}}", "A4": "{{headers}}", "A5": "{{rows}}", "A6": "Total"},
+			want:  panelRegion{start: 2, end: 5, headersRow: 4, rowsRow: 5},
+		},
+		{
+			name:  "title with other text is not the start of the region",
+			cells: map[string]string{"A2": "Stock of {{title}}", "A4": "{{headers}}", "A5": "{{rows}}"},
+			want:  panelRegion{start: 4, end: 5, headersRow: 4, rowsRow: 5},
+		},
+		{
+			name:  "title below the rows",
+			cells: map[string]string{"A4": "{{headers}}", "A5": "{{rows}}", "A7": "{{title}}"},
+			want:  panelRegion{start: 4, end: 5, headersRow: 4, rowsRow: 5},
+		},
+		{
+			name:    "only the start is marked",
+			cells:   map[string]string{"A2": "{{panel.start}}", "A4": "{{headers}}", "A5": "{{rows}}"},
+			wantErr: "with both",
+		},
+		{
+			name:    "region without the rows",
+			cells:   map[string]string{"A2": "{{panel.start}}", "A4": "{{headers}}", "A5": "{{panel.end}}", "A6": "{{rows}}"},
+			wantErr: "must include",
+		},
+		{
+			name:    "no rows placeholder",
+			cells:   map[string]string{"A4": "{{headers}}"},
+			wantErr: "missing the placeholders",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := openWorkbook(t, workbook(t, TEMPLATE_SHEET, test.cells))
+			got, err := findPanelRegion(file, TEMPLATE_SHEET)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("findPanelRegion returned %v, want an error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findPanelRegion returned an error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("findPanelRegion = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanStackedSheet(t *testing.T) {
+	file := openWorkbook(t, workbook(t, TEMPLATE_SHEET, map[string]string{
+		"A1": "Stock report", "A2": "{{panel.start}}", "A3": "{{title}}", "A4": "{{headers}}",
+		"A5": "{{rows}}", "A6": "Total", "A7": "{{panel.end}}", "A8": "Prepared by the pharmacy",
+	}))
+
+	rows := func(count int) [][]interface{} {
+		return make([][]interface{}, count)
+	}
+	r := &Report{
+		file:    file,
+		details: ReportDetails{ScheduleName: "Weekly stock"},
+		sheets: []api.TablePanel{
+			{Title: "Stock", Rows: rows(3)},
+			{Title: "Orders"},
+			{Title: "Stock over time", Type: api.PanelTypeTimeSeries, Rows: rows(2)},
+			{Title: "Failed chart", Type: api.PanelTypeTimeSeries, Rows: rows(2)},
+			{Title: "Expiring", Rows: rows(1)},
+		},
+		sheetErrors: map[int]error{3: errors.New("query failed")},
+	}
+
+	layout, err := r.planStackedSheet()
+	if err != nil {
+		t.Fatalf("planStackedSheet returned an error: %v", err)
+	}
+
+	if layout.name != "Weekly stock" {
+		t.Errorf("the sheet is named %q, want Weekly stock", layout.name)
+	}
+	if want := (panelRegion{start: 2, end: 7, headersRow: 4, rowsRow: 5}); layout.region != want {
+		t.Errorf("the region is %+v, want %+v", layout.region, want)
+	}
+
+	// each copy of the six rows of the region grows by the panel's rows after the first and is
+	// followed by two empty rows, and the chart leaves room for the chart beside its table
+	want := []panelBlock{{top: 2, added: 2}, {top: 12, added: 0}, {top: 20, added: 1}, {top: 42, added: 1}, {top: 51, added: 0}}
+	if !reflect.DeepEqual(layout.blocks, want) {
+		t.Errorf("the blocks are %+v, want %+v", layout.blocks, want)
+	}
+	if layout.footerShift != 49 {
+		t.Errorf("the footer moves down %d rows, want 49", layout.footerShift)
+	}
+
+	links := layout.links()
+	if links[0] != "'Weekly stock'!A2" || links[4] != "'Weekly stock'!A51" {
+		t.Errorf("the contents links are %q, want the start of each block", links)
+	}
+
+	block := layout.blocks[0]
+	for _, test := range []struct {
+		row         int
+		first, last int
+	}{
+		{row: 3, first: 3, last: 3},
+		{row: 4, first: 4, last: 4},
+		{row: 5, first: 5, last: 7},
+		{row: 6, first: 8, last: 8},
+		{row: 7, first: 9, last: 9},
+	} {
+		if first, last := block.rows(layout.region, test.row); first != test.first || last != test.last {
+			t.Errorf("row %d of the first panel is written to rows %d to %d, want %d to %d", test.row, first, last, test.first, test.last)
+		}
+	}
+
+	if first, _ := layout.blocks[2].rows(layout.region, 6); first != 25 {
+		t.Errorf("the chart panel's Total row is written to row %d, want 25", first)
+	}
+	if row, _ := layout.move(1); row != 1 {
+		t.Errorf("the template's first row is written to row %d, want 1", row)
+	}
+	if row, _ := layout.move(8); row != 57 {
+		t.Errorf("the row below the region is written to row %d, want 57", row)
+	}
+}
@@ -0,0 +1,155 @@
+package reportEmailer
+
+import (
+	"excel-report-email-scheduler/pkg/api"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	SUMMARY_SHEET       = "Contents"
+	SUMMARY_HEADERS_ROW = 4
+	SUMMARY_LINK_COLOR  = "#1F60C4"
+)
+
+var SUMMARY_HEADERS = []string{"Sheet", "Rows", "From", "To", "Variables", "Dashboard"}
+var SUMMARY_WIDTHS = []float64{40, 10, 20, 20, 50, 20}
+
+// selectedVariablesText lists the options selected for each of the panel's variables, such as
+// "store: Central, North; item: Amoxicillin".
+func selectedVariablesText(sheet api.TablePanel) string {
+	names := make([]string, 0, len(sheet.SelectedVariables))
+	for name := range sheet.SelectedVariables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	variables := []string{}
+	for _, name := range names {
+		if options := sheet.SelectedVariables[name]; len(options) > 0 {
+			variables = append(variables, name+": "+strings.Join(options, ", "))
+		}
+	}
+	return strings.Join(variables, "; ")
+}
+
+// panelURL returns the link to the panel on its dashboard in Grafana, showing the time range
+// and variables of the report. It is empty when the dashboard is not known.
+func (r *Report) panelURL(sheet api.TablePanel) string {
+	if r.grafanaURL == "" || sheet.DashboardUID == "" {
+		return ""
+	}
+
+	query := url.Values{}
+	query.Set("viewPanel", strconv.Itoa(sheet.ID))
+	if sheet.From != "" {
+		query.Set("from", sheet.From)
+	}
+	if sheet.To != "" {
+		query.Set("to", sheet.To)
+	}
+	for name, options := range sheet.SelectedVariables {
+		for _, option := range options {
+			query.Add("var-"+name, option)
+		}
+	}
+
+	return r.grafanaURL + "/d/" + url.PathEscape(sheet.DashboardUID) + "?" + query.Encode()
+}
+
+// writeSummarySheet adds a sheet listing the sheets of the report, with how many rows each has,
+// its time range and variables, and links to the sheet and to its panel in Grafana. It is
+// added before the panels' sheets, so it is the first sheet of the workbook. links are where
+// each panel's table starts, such as 'Stock'!A1.
+func (r *Report) writeSummarySheet(links []string) error {
+	summarySheet := r.summarySheetName()
+	if _, err := r.file.NewSheet(summarySheet); err != nil {
+		return err
+	}
+
+	titleStyle, err := r.file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+	if err != nil {
+		return err
+	}
+	headerStyle, err := r.file.NewStyle(&excelize.Style{
+		Font:   &excelize.Font{Bold: true},
+		Border: []excelize.Border{{Type: "bottom", Color: "#000000", Style: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	linkStyle, err := r.file.NewStyle(&excelize.Style{Font: &excelize.Font{Color: SUMMARY_LINK_COLOR, Underline: "single"}})
+	if err != nil {
+		return err
+	}
+
+	cells := map[string]interface{}{
+		"A1": r.title(),
+		"A2": "Generated " + r.formatTime(r.generatedAt, "", DEFAULT_DATE_LAYOUT),
+	}
+	for i, header := range SUMMARY_HEADERS {
+		cells[r.createCellRef(i, SUMMARY_HEADERS_ROW)] = header
+	}
+
+	for i, sheet := range r.sheets {
+		rowNumber := SUMMARY_HEADERS_ROW + 1 + i
+		sheetCell := r.createCellRef(0, rowNumber)
+		cells[sheetCell] = sheet.Title
+		if err := r.file.SetCellHyperLink(summarySheet, sheetCell, links[i], "Location"); err != nil {
+			return err
+		}
+
+		if err, failed := r.sheetErrors[i]; failed {
+			cells[r.createCellRef(1, rowNumber)] = "Could not load data: " + err.Error()
+		} else {
+			cells[r.createCellRef(1, rowNumber)] = len(sheet.Rows)
+		}
+		from, _ := r.placeholderValue(sheet, "period.from", "")
+		to, _ := r.placeholderValue(sheet, "period.to", "")
+		cells[r.createCellRef(2, rowNumber)] = from
+		cells[r.createCellRef(3, rowNumber)] = to
+		cells[r.createCellRef(4, rowNumber)] = selectedVariablesText(sheet)
+
+		if link := r.panelURL(sheet); link != "" {
+			dashboardCell := r.createCellRef(5, rowNumber)
+			cells[dashboardCell] = "Open in Grafana"
+			if err := r.file.SetCellHyperLink(summarySheet, dashboardCell, link, "External"); err != nil {
+				return err
+			}
+			if err := r.file.SetCellStyle(summarySheet, dashboardCell, dashboardCell, linkStyle); err != nil {
+				return err
+			}
+		}
+	}
+
+	for cell, value := range cells {
+		if err := r.file.SetCellValue(summarySheet, cell, value); err != nil {
+			return err
+		}
+	}
+
+	if err := r.file.SetCellStyle(summarySheet, "A1", "A1", titleStyle); err != nil {
+		return err
+	}
+	headers := r.createCellRef(0, SUMMARY_HEADERS_ROW)
+	if err := r.file.SetCellStyle(summarySheet, headers, r.createCellRef(len(SUMMARY_HEADERS)-1, SUMMARY_HEADERS_ROW), headerStyle); err != nil {
+		return err
+	}
+	if len(r.sheets) > 0 {
+		if err := r.file.SetCellStyle(summarySheet, r.createCellRef(0, SUMMARY_HEADERS_ROW+1), r.createCellRef(0, SUMMARY_HEADERS_ROW+len(r.sheets)), linkStyle); err != nil {
+			return err
+		}
+	}
+
+	for i, width := range SUMMARY_WIDTHS {
+		if err := r.file.SetColWidth(summarySheet, intToCol(i), intToCol(i), width); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package reportEmailer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"excel-report-email-scheduler/pkg/api"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestPanelURL(t *testing.T) {
+	panel := api.TablePanel{
+		ID:                4,
+		DashboardUID:      "stock levels",
+		From:              "1714521600000",
+		To:                "1717199999999",
+		SelectedVariables: map[string][]string{"store": {"Central", "North"}},
+	}
+
+	tests := []struct {
+		name       string
+		grafanaURL string
+		sheet      api.TablePanel
+		want       string
+	}{
+		{
+			name:       "link to the panel with the report's time range and variables",
+			grafanaURL: "http://grafana:3000",
+			sheet:      panel,
+			want:       "http://grafana:3000/d/stock%20levels?from=1714521600000&to=1717199999999&var-store=Central&var-store=North&viewPanel=4",
+		},
+		{
+			name:       "no time range",
+			grafanaURL: "http://grafana:3000",
+			sheet:      api.TablePanel{ID: 4, DashboardUID: "abc"},
+			want:       "http://grafana:3000/d/abc?viewPanel=4",
+		},
+		{
+			name:  "Grafana's address is not known",
+			sheet: panel,
+		},
+		{
+			name:       "the dashboard is not known",
+			grafanaURL: "http://grafana:3000",
+			sheet:      api.TablePanel{ID: 4},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Report{grafanaURL: test.grafanaURL}
+			if got := r.panelURL(test.sheet); got != test.want {
+				t.Errorf("panelURL = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteSummarySheet(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	r := &Report{
+		file:        file,
+		location:    time.UTC,
+		generatedAt: time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC),
+		grafanaURL:  "http://grafana:3000",
+		sheets: []api.TablePanel{
+			{ID: 1, Title: "Stock", DashboardUID: "abc", Rows: [][]interface{}{{"a"}, {"b"}}},
+			{ID: 2, Title: "Sales"},
+		},
+		sheetErrors:  map[int]error{1: errors.New("timed out")},
+		summarySheet: true,
+	}
+	links := []string{"'Stock'!A1", "'Sales'!A1"}
+	if err := r.writeSummarySheet(links); err != nil {
+		t.Fatalf("writeSummarySheet returned an error: %v", err)
+	}
+
+	summary := SUMMARY_SHEET
+	tests := []struct {
+		cell     string
+		value    string
+		linked   bool
+		location string
+	}{
+		{cell: "A5", value: "Stock", linked: true, location: links[0]},
+		{cell: "B5", value: "2"},
+		{cell: "F5", value: "Open in Grafana", linked: true, location: "http://grafana:3000/d/abc?viewPanel=1"},
+		{cell: "A6", value: "Sales", linked: true, location: links[1]},
+		{cell: "B6", value: "Could not load data: timed out"},
+		{cell: "F6"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.cell, func(t *testing.T) {
+			value, err := file.GetCellValue(summary, test.cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != test.value {
+				t.Errorf("%s = %q, want %q", test.cell, value, test.value)
+			}
+
+			linked, location, err := file.GetCellHyperLink(summary, test.cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if linked != test.linked || location != test.location {
+				t.Errorf("%s links to %q (%v), want %q (%v)", test.cell, location, linked, test.location, test.linked)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package reportEmailer
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
+)
+
+// TEMPLATE_SHEET is the sheet of a template which is copied for each panel of a report. It
+// must be the first sheet of the workbook.
+const TEMPLATE_SHEET = "templateSheet"
+
+// REQUIRED_PLACEHOLDERS are the cells of the template sheet the panel's table is written at.
+var REQUIRED_PLACEHOLDERS = []string{"{{headers}}", "{{rows}}"}
+
+// PANEL_START_PLACEHOLDER and PANEL_END_PLACEHOLDER mark the first and last rows of the
+// template sheet which are written for each panel when the panels are stacked on one sheet.
+const (
+	PANEL_START_PLACEHOLDER = "{{panel.start}}"
+	PANEL_END_PLACEHOLDER   = "{{panel.end}}"
+)
+
+// TEMPLATES_DIRECTORY is the folder of the data directory uploaded templates are kept in, apart
+// from the reports, so no schedule's report can be named like a template.
+const TEMPLATES_DIRECTORY = "templates"
+
+// TemplatePath returns the file of the uploaded template with the given ID, or of the
+// default template when the ID is empty.
+func TemplatePath(templateID string) string {
+	if templateID == "" {
+		return GetFilePath("template")
+	}
+	return GetFilePath(filepath.Join(TEMPLATES_DIRECTORY, templateID))
+}
+
+// ValidateTemplate checks an uploaded workbook can be used as a template: its first sheet is
+// the template sheet and has each of the required placeholders, within the rows marked for
+// each panel if it marks them.
+func ValidateTemplate(content []byte) error {
+	f, err := excelize.OpenReader(bytes.NewReader(content))
+	if err != nil {
+		return errors.New("the template is not an xlsx file")
+	}
+	defer f.Close()
+
+	if index, err := f.GetSheetIndex(TEMPLATE_SHEET); err != nil || index != 0 {
+		return errors.New("the first sheet of the template must be named " + TEMPLATE_SHEET)
+	}
+
+	missing := []string{}
+	for _, placeholder := range REQUIRED_PLACEHOLDERS {
+		if refs, err := f.SearchSheet(TEMPLATE_SHEET, placeholder); err != nil || len(refs) == 0 {
+			missing = append(missing, placeholder)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.New("the template sheet is missing the placeholders " + strings.Join(missing, ", "))
+	}
+
+	if _, err := findPanelRegion(f, TEMPLATE_SHEET); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// uploadedTemplatePath returns the file of an uploaded template, which is named after its ID.
+// IDs which are not UUIDs are turned down, so the path cannot leave the data directory.
+func uploadedTemplatePath(templateID string) (string, error) {
+	if _, err := uuid.Parse(templateID); err != nil {
+		return "", errors.New("invalid template id: " + templateID)
+	}
+	return TemplatePath(templateID), nil
+}
+
+// StageTemplate writes an uploaded template to a temporary file in the templates folder, which
+// InstallTemplate moves into place once the template has been stored.
+func StageTemplate(content []byte) (string, error) {
+	directory := filepath.Dir(TemplatePath(uuid.Nil.String()))
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp(directory, "upload-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+// InstallTemplate moves a template written by StageTemplate into place as the file of the
+// template with the given ID, replacing any earlier upload of it.
+func InstallTemplate(stagedPath string, templateID string) error {
+	path, err := uploadedTemplatePath(templateID)
+	if err != nil {
+		return err
+	}
+	return os.Rename(stagedPath, path)
+}
+
+// DiscardTemplate removes a template written by StageTemplate which will not be installed.
+func DiscardTemplate(stagedPath string) error {
+	err := os.Remove(stagedPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DeleteTemplate removes an uploaded template from the data directory.
+func DeleteTemplate(templateID string) error {
+	path, err := uploadedTemplatePath(templateID)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package reportEmailer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// workbook returns the content of an xlsx file whose first sheet is named sheetName and holds
+// the given cell values.
+func workbook(t *testing.T, sheetName string, cells map[string]string) []byte {
+	t.Helper()
+	file := excelize.NewFile()
+	defer file.Close()
+	if err := file.SetSheetName("Sheet1", sheetName); err != nil {
+		t.Fatal(err)
+	}
+	for cell, value := range cells {
+		if err := file.SetCellValue(sheetName, cell, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buffer, err := file.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr string
+	}{
+		{
+			name:    "template",
+			content: workbook(t, TEMPLATE_SHEET, map[string]string{"A1": "{{title}}", "A3": "{{headers}}", "A4": "{{rows}}"}),
+		},
+		{
+			name:    "not an xlsx file",
+			content: []byte("name,quantity\n"),
+			wantErr: "not an xlsx file",
+		},
+		{
+			name:    "first sheet is not the template sheet",
+			content: workbook(t, "Sheet1", map[string]string{"A3": "{{headers}}", "A4": "{{rows}}"}),
+			wantErr: "must be named " + TEMPLATE_SHEET,
+		},
+		{
+			name:    "no rows placeholder",
+			content: workbook(t, TEMPLATE_SHEET, map[string]string{"A3": "{{headers}}"}),
+			wantErr: "missing the placeholders {{rows}}",
+		},
+		{
+			name:    "no placeholders",
+			content: workbook(t, TEMPLATE_SHEET, map[string]string{"A1": "{{title}}"}),
+			wantErr: "missing the placeholders {{headers}}, {{rows}}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateTemplate(test.content)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateTemplate returned an error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("ValidateTemplate returned %v, want an error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestUploadedTemplatePath(t *testing.T) {
+	id := "0b7f5e0e-3c1a-4a8e-9b1e-6f2b8c4d7a90"
+	path, err := uploadedTemplatePath(id)
+	if err != nil {
+		t.Fatalf("uploadedTemplatePath(%q) returned an error: %v", id, err)
+	}
+	if want := filepath.Join(TEMPLATES_DIRECTORY, id+".xlsx"); !strings.HasSuffix(path, want) {
+		t.Errorf("uploadedTemplatePath(%q) = %q, want it in the %s folder", id, path, TEMPLATES_DIRECTORY)
+	}
+
+	for _, id := range []string{"", "template", "../template", "../../../etc/passwd"} {
+		if path, err := uploadedTemplatePath(id); err == nil {
+			t.Errorf("uploadedTemplatePath(%q) = %q, want an error", id, path)
+		}
+	}
+}
@@ -18,6 +18,8 @@ type ExportPanelArgs struct {
 	PanelID     int    `json:"panelID"`
 	Query       string `json:"query"`
 	Title       string `json:"title"`
+	// Format is the file format to export to, xlsx if not set
+	Format string `json:"format"`
 }
 
 func ExportPanelArgsFields() string {
@@ -25,6 +27,7 @@ func ExportPanelArgsFields() string {
 		"DashboardID string\n\t" +
 		"Query string\n\t" +
 		"Title string\n\t" +
+		"Format string (xlsx, csv or pdf)\n\t" +
 		"\n}"
 }
 
@@ -64,11 +67,10 @@ func (server *HttpServer) exportPanel(rw http.ResponseWriter, request *http.Requ
 		return
 	}
 
-	reportEmailer.NewReportEmailer(server.db)
-	templatePath := reportEmailer.GetFilePath("template")
+	templatePath := reportEmailer.TemplatePath("")
 	reporter := reportEmailer.NewReporter(templatePath)
 
-	url, err := reporter.ExportPanel(authConfig, settings.DatasourceID, args.DashboardID, args.PanelID, args.Query, args.Title)
+	url, err := reporter.ExportPanel(authConfig, settings.DatasourceID, args.DashboardID, args.PanelID, args.Query, args.Title, args.Format)
 	if err != nil {
 		server.Error(rw, errors.Wrap(err, frame.Function))
 		return
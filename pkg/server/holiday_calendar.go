@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"excel-report-email-scheduler/pkg/datasource"
+	"excel-report-email-scheduler/pkg/ereserror"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// holidayCalendarBody is a holiday calendar as it is uploaded. Content, when given, is an iCal
+// file or a list of dates which replaces Holidays.
+type holidayCalendarBody struct {
+	datasource.HolidayCalendar
+	Content string `json:"content"`
+}
+
+func (server *HttpServer) fetchHolidayCalendars(rw http.ResponseWriter, request *http.Request) {
+	frame := trace()
+
+	calendars, err := server.db.GetHolidayCalendars()
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.NewEncoder(rw).Encode(calendars)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (server *HttpServer) fetchSingleHolidayCalendar(rw http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+	frame := trace()
+
+	calendar, err := server.db.GetHolidayCalendar(id)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.NewEncoder(rw).Encode(calendar)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (server *HttpServer) createHolidayCalendar(rw http.ResponseWriter, request *http.Request) {
+	frame := trace()
+	var body holidayCalendarBody
+
+	requestBody, err := request.GetBody()
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	bodyAsBytes, err := ioutil.ReadAll(requestBody)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.Unmarshal(bodyAsBytes, &body)
+	if err != nil {
+		server.Error(rw, errors.Wrap(NewRequestBodyError(err, datasource.HolidayCalendarFields()), frame.Function))
+		return
+	}
+
+	calendar := body.HolidayCalendar
+	if body.Content != "" {
+		calendar.Holidays, err = datasource.ParseHolidays(body.Content)
+		if err != nil {
+			err = ereserror.New(400, errors.Wrap(err, frame.Function), "Could not read the holidays: "+err.Error())
+			server.Error(rw, err)
+			return
+		}
+	}
+
+	err = server.validator.HolidayCalendarMustBeValid(calendar)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	saved, err := server.db.CreateHolidayCalendar(calendar)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.NewEncoder(rw).Encode(saved)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (server *HttpServer) deleteHolidayCalendar(rw http.ResponseWriter, request *http.Request) {
+	frame := trace()
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	err := server.db.DeleteHolidayCalendar(id)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	server.Success(rw, "Holiday calendar successfully deleted")
+}
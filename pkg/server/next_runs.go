@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"excel-report-email-scheduler/pkg/datasource"
+	"excel-report-email-scheduler/pkg/ereserror"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+const DEFAULT_NEXT_RUNS = 5
+const MAX_NEXT_RUNS = 100
+
+type NextRun struct {
+	// NextReportTime is the unix time the schedule fires at
+	NextReportTime int `json:"nextReportTime"`
+	// LocalTime is the same time in the schedule's time zone, formatted as RFC 3339
+	LocalTime string `json:"localTime"`
+}
+
+func nextRunsCount(request *http.Request) (int, error) {
+	value := request.URL.Query().Get("count")
+	if value == "" {
+		return DEFAULT_NEXT_RUNS, nil
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil || count <= 0 || count > MAX_NEXT_RUNS {
+		err = errors.New("count must be a number from 1 to " + strconv.Itoa(MAX_NEXT_RUNS))
+		return 0, ereserror.New(400, err, err.Error())
+	}
+
+	return count, nil
+}
+
+func (server *HttpServer) writeNextRuns(rw http.ResponseWriter, schedule datasource.Schedule, count int) error {
+	nextRuns := []NextRun{}
+	for _, reportTime := range schedule.UpcomingReportTimes(time.Now(), count) {
+		nextRuns = append(nextRuns, NextRun{NextReportTime: int(reportTime.Unix()), LocalTime: reportTime.Format(time.RFC3339)})
+	}
+
+	return json.NewEncoder(rw).Encode(nextRuns)
+}
+
+func (server *HttpServer) fetchScheduleNextRuns(rw http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+	frame := trace()
+
+	count, err := nextRunsCount(request)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	schedule, err := server.db.GetSchedule(id)
+	if err != nil {
+		err = ereserror.New(404, errors.Wrap(err, frame.Function), "Could not find schedule with id: "+id)
+		server.Error(rw, err)
+		return
+	}
+
+	err = server.writeNextRuns(rw, *schedule, count)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// previewNextRuns returns the report times of a schedule which has not been saved, given in the body.
+func (server *HttpServer) previewNextRuns(rw http.ResponseWriter, request *http.Request) {
+	frame := trace()
+	var schedule datasource.Schedule
+
+	count, err := nextRunsCount(request)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	requestBody, err := request.GetBody()
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	bodyAsBytes, err := ioutil.ReadAll(requestBody)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.Unmarshal(bodyAsBytes, &schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleCronExpressionMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleTimeZoneMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleBusinessDaysMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.db.AttachHolidays(&schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	// the body describes a schedule as it would be saved, so its times are calculated afresh
+	schedule.NextReportTime = 0
+
+	err = server.writeNextRuns(rw, schedule, count)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"excel-report-email-scheduler/pkg/ereserror"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNextRunsCount(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{query: "", want: DEFAULT_NEXT_RUNS},
+		{query: "?count=", want: DEFAULT_NEXT_RUNS},
+		{query: "?count=1", want: 1},
+		{query: "?count=12", want: 12},
+		{query: "?count=100", want: MAX_NEXT_RUNS},
+		{query: "?count=101", wantErr: true},
+		{query: "?count=0", wantErr: true},
+		{query: "?count=-3", wantErr: true},
+		{query: "?count=ten", wantErr: true},
+		{query: "?count=2.5", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/schedule/abc/next-runs"+test.query, nil)
+			got, err := nextRunsCount(request)
+			if test.wantErr {
+				var eresErr ereserror.EresError
+				if !errors.As(err, &eresErr) || eresErr.Code != 400 {
+					t.Errorf("nextRunsCount = %d, %v, want a 400 error", got, err)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("nextRunsCount = %d, %v, want %d", got, err, test.want)
+			}
+		})
+	}
+}
@@ -89,6 +89,78 @@ func (server *HttpServer) createSchedule(rw http.ResponseWriter, request *http.R
 		return
 	}
 
+	err = server.validator.ScheduleCronExpressionMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleTimeZoneMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleRetryPolicyMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleCatchUpPolicyMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleFormatMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleLayoutMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleTemplateMustExist(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleInlineRowsMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleDateRangeMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleLookbacksMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleBusinessDaysMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.validator.ScheduleDependenciesMustBeValid(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
 	_, err = server.db.CreateScheduleWithDetails(schedule)
 	if err != nil {
 		server.Error(rw, errors.Wrap(err, frame.Function))
@@ -118,3 +190,31 @@ func (server *HttpServer) deleteSchedule(rw http.ResponseWriter, request *http.R
 
 	server.Success(rw, "Schedule successfully deleted")
 }
+
+func (server *HttpServer) pauseSchedule(rw http.ResponseWriter, request *http.Request) {
+	server.setScheduleEnabled(rw, request, false)
+}
+
+func (server *HttpServer) resumeSchedule(rw http.ResponseWriter, request *http.Request) {
+	server.setScheduleEnabled(rw, request, true)
+}
+
+func (server *HttpServer) setScheduleEnabled(rw http.ResponseWriter, request *http.Request, enabled bool) {
+	frame := trace()
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	schedule, err := server.db.SetScheduleEnabled(id, enabled)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.NewEncoder(rw).Encode(schedule)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
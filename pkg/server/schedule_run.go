@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"excel-report-email-scheduler/pkg/auth"
+	"excel-report-email-scheduler/pkg/ereserror"
+	reportEmailer "excel-report-email-scheduler/pkg/report-emailer"
+	"excel-report-email-scheduler/pkg/setting"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/pkg/errors"
+)
+
+func RunOptionsFields() string {
+	return "\n{\n\trecipients []string" +
+		"\n\tonlyMe bool\n}"
+}
+
+func (server *HttpServer) fetchScheduleRuns(rw http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+	frame := trace()
+
+	runs, err := server.db.GetScheduleRuns(id)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.NewEncoder(rw).Encode(runs)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (server *HttpServer) fetchSingleScheduleRun(rw http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+	runID := vars["runID"]
+	frame := trace()
+
+	run, err := server.db.GetScheduleRun(runID)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	if run.ScheduleID != id {
+		err = ereserror.New(404, errors.New("run belongs to another schedule"), "Could not find schedule run with id: "+runID)
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.NewEncoder(rw).Encode(run)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (server *HttpServer) runSchedule(rw http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+	frame := trace()
+	var options reportEmailer.RunOptions
+
+	requestBody, err := request.GetBody()
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	bodyAsBytes, err := ioutil.ReadAll(requestBody)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	if len(bodyAsBytes) > 0 {
+		err = json.Unmarshal(bodyAsBytes, &options)
+		if err != nil {
+			server.Error(rw, errors.Wrap(NewRequestBodyError(err, RunOptionsFields()), frame.Function))
+			return
+		}
+	}
+
+	if options.OnlyMe {
+		user := httpadapter.UserFromContext(request.Context())
+		if user == nil || user.Email == "" {
+			err = ereserror.New(400, errors.New("requesting user has no email address"), "Your user does not have an email address to send the report to")
+			server.Error(rw, errors.Wrap(err, frame.Function))
+			return
+		}
+		options.RequesterEmail = user.Email
+	}
+
+	settings, err := setting.NewSettings(request.Context())
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	authConfig, err := auth.NewAuthConfig(settings)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	emailConfig, err := auth.NewEmailConfig(settings)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	schedule, err := server.db.GetSchedule(id)
+	if err != nil {
+		err = ereserror.New(404, errors.Wrap(err, frame.Function), "Could not find schedule with id: "+id)
+		server.Error(rw, err)
+		return
+	}
+
+	em := reportEmailer.NewEmailSender(emailConfig)
+
+	run, err := server.reportEmailer.RunNow(*schedule, authConfig, settings.DatasourceID, *em, options)
+	if errors.Is(err, reportEmailer.ErrScheduleInProgress) {
+		err = ereserror.New(409, err, "This schedule is already queued or running")
+	}
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(rw).Encode(run)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"excel-report-email-scheduler/pkg/datasource"
 	"excel-report-email-scheduler/pkg/ereserror"
+	reportEmailer "excel-report-email-scheduler/pkg/report-emailer"
 	"excel-report-email-scheduler/pkg/validation"
 	"net/http"
 
@@ -19,14 +20,15 @@ import (
 )
 
 type HttpServer struct {
-	db        *datasource.MsupplyEresDatasource
-	validator *validation.Validation
+	db            *datasource.MsupplyEresDatasource
+	validator     *validation.Validation
+	reportEmailer *reportEmailer.ReportEmailer
 }
 
-func NewServer(sqliteDatasource *datasource.MsupplyEresDatasource) *HttpServer {
+func NewServer(sqliteDatasource *datasource.MsupplyEresDatasource, emailer *reportEmailer.ReportEmailer) *HttpServer {
 	validator, _ := validation.New(sqliteDatasource)
 
-	return &HttpServer{db: sqliteDatasource, validator: validator}
+	return &HttpServer{db: sqliteDatasource, validator: validator, reportEmailer: emailer}
 }
 
 func (server *HttpServer) ResourceHandler(mSupplyEresDatasource *datasource.MsupplyEresDatasource) backend.CallResourceHandler {
@@ -36,8 +38,25 @@ func (server *HttpServer) ResourceHandler(mSupplyEresDatasource *datasource.Msup
 
 	mux.HandleFunc("/schedule", bugsnag.HandlerFunc(server.fetchSchedules)).Methods("GET")
 	mux.HandleFunc("/schedule/{id}", bugsnag.HandlerFunc(server.fetchSingleSchedule)).Methods("GET")
+	mux.HandleFunc("/schedule/next-runs", bugsnag.HandlerFunc(server.previewNextRuns)).Methods("POST")
+	mux.HandleFunc("/schedule/{id}/next-runs", bugsnag.HandlerFunc(server.fetchScheduleNextRuns)).Methods("GET")
 	mux.HandleFunc("/schedule", bugsnag.HandlerFunc(server.createSchedule)).Methods("POST")
 	mux.HandleFunc("/schedule/{id}", bugsnag.HandlerFunc(server.deleteSchedule)).Methods("DELETE")
+	mux.HandleFunc("/schedule/{id}/runs", bugsnag.HandlerFunc(server.fetchScheduleRuns)).Methods("GET")
+	mux.HandleFunc("/schedule/{id}/runs/{runID}", bugsnag.HandlerFunc(server.fetchSingleScheduleRun)).Methods("GET")
+	mux.HandleFunc("/schedule/{id}/run", bugsnag.HandlerFunc(server.runSchedule)).Methods("POST")
+	mux.HandleFunc("/schedule/{id}/pause", bugsnag.HandlerFunc(server.pauseSchedule)).Methods("POST")
+	mux.HandleFunc("/schedule/{id}/resume", bugsnag.HandlerFunc(server.resumeSchedule)).Methods("POST")
+
+	mux.HandleFunc("/holiday-calendar", bugsnag.HandlerFunc(server.fetchHolidayCalendars)).Methods("GET")
+	mux.HandleFunc("/holiday-calendar/{id}", bugsnag.HandlerFunc(server.fetchSingleHolidayCalendar)).Methods("GET")
+	mux.HandleFunc("/holiday-calendar", bugsnag.HandlerFunc(server.createHolidayCalendar)).Methods("POST")
+	mux.HandleFunc("/holiday-calendar/{id}", bugsnag.HandlerFunc(server.deleteHolidayCalendar)).Methods("DELETE")
+
+	mux.HandleFunc("/template", bugsnag.HandlerFunc(server.fetchTemplates)).Methods("GET")
+	mux.HandleFunc("/template/{id}", bugsnag.HandlerFunc(server.fetchSingleTemplate)).Methods("GET")
+	mux.HandleFunc("/template", bugsnag.HandlerFunc(server.createTemplate)).Methods("POST")
+	mux.HandleFunc("/template/{id}", bugsnag.HandlerFunc(server.deleteTemplate)).Methods("DELETE")
 
 	mux.HandleFunc("/report-group", bugsnag.HandlerFunc(server.fetchReportGroupsWithMembers)).Methods("GET")
 	mux.HandleFunc("/report-group/{id}", bugsnag.HandlerFunc(server.fetchSingleReportGroupWithMembers)).Methods("GET")
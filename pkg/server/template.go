@@ -0,0 +1,193 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"excel-report-email-scheduler/pkg/datasource"
+	"excel-report-email-scheduler/pkg/ereserror"
+	reportEmailer "excel-report-email-scheduler/pkg/report-emailer"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// templateBody is a template as it is uploaded. Content is the xlsx file, base64 encoded. It
+// can be left out when only renaming a template.
+type templateBody struct {
+	datasource.Template
+	Content string `json:"content"`
+}
+
+func (server *HttpServer) fetchTemplates(rw http.ResponseWriter, request *http.Request) {
+	frame := trace()
+
+	templates, err := server.db.GetTemplates()
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.NewEncoder(rw).Encode(templates)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (server *HttpServer) fetchSingleTemplate(rw http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+	frame := trace()
+
+	template, err := server.db.GetTemplate(id)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.NewEncoder(rw).Encode(template)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (server *HttpServer) createTemplate(rw http.ResponseWriter, request *http.Request) {
+	frame := trace()
+	var body templateBody
+
+	requestBody, err := request.GetBody()
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	bodyAsBytes, err := ioutil.ReadAll(requestBody)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = json.Unmarshal(bodyAsBytes, &body)
+	if err != nil {
+		server.Error(rw, errors.Wrap(NewRequestBodyError(err, datasource.TemplateFields()), frame.Function))
+		return
+	}
+
+	template := body.Template
+	err = server.validator.TemplateMustBeValid(template)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	isNew := template.ID == ""
+	if !isNew {
+		existing, err := server.db.GetTemplate(template.ID)
+		if err != nil {
+			server.Error(rw, errors.Wrap(err, frame.Function))
+			return
+		}
+		if body.Content == "" {
+			template.FileName = existing.FileName
+		}
+	}
+
+	var content []byte
+	if body.Content == "" {
+		if isNew {
+			err = ereserror.New(400, errors.Wrap(errors.New("no template file"), frame.Function), "A template must be uploaded with its file")
+			server.Error(rw, err)
+			return
+		}
+	} else {
+		content, err = base64.StdEncoding.DecodeString(body.Content)
+		if err != nil {
+			err = ereserror.New(400, errors.Wrap(err, frame.Function), "The template file must be base64 encoded")
+			server.Error(rw, err)
+			return
+		}
+
+		err = reportEmailer.ValidateTemplate(content)
+		if err != nil {
+			err = ereserror.New(400, errors.Wrap(err, frame.Function), "Invalid template: "+err.Error())
+			server.Error(rw, err)
+			return
+		}
+	}
+
+	// the file is written before the template is stored and only moved into place after, so
+	// neither is left without the other
+	stagedPath := ""
+	if content != nil {
+		stagedPath, err = reportEmailer.StageTemplate(content)
+		if err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not save the template file")
+			server.Error(rw, err)
+			return
+		}
+	}
+
+	saved, err := server.db.CreateTemplate(template)
+	if err != nil {
+		if stagedPath != "" {
+			reportEmailer.DiscardTemplate(stagedPath)
+		}
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	if stagedPath != "" {
+		err = reportEmailer.InstallTemplate(stagedPath, saved.ID)
+		if err != nil {
+			reportEmailer.DiscardTemplate(stagedPath)
+			if isNew {
+				server.db.DeleteTemplate(saved.ID)
+			}
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not save the template file")
+			server.Error(rw, err)
+			return
+		}
+	}
+
+	err = json.NewEncoder(rw).Encode(saved)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (server *HttpServer) deleteTemplate(rw http.ResponseWriter, request *http.Request) {
+	frame := trace()
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	template, err := server.db.GetTemplate(id)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = server.db.DeleteTemplate(template.ID)
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
+
+	err = reportEmailer.DeleteTemplate(template.ID)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not delete the template file")
+		server.Error(rw, err)
+		return
+	}
+
+	server.Success(rw, "Template successfully deleted")
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"excel-report-email-scheduler/pkg/auth"
+	"excel-report-email-scheduler/pkg/ereserror"
 	reportEmailer "excel-report-email-scheduler/pkg/report-emailer"
 	"excel-report-email-scheduler/pkg/setting"
 	"net/http"
@@ -45,9 +46,14 @@ func (server *HttpServer) testEmail(rw http.ResponseWriter, request *http.Reques
 
 	em := reportEmailer.NewEmailSender(emailConfig)
 
-	re := reportEmailer.NewReportEmailer(server.db)
-
-	re.CreateReport(*schedule, authConfig, settings.DatasourceID, *em)
+	err = server.reportEmailer.CreateReport(*schedule, authConfig, settings.DatasourceID, *em)
+	if errors.Is(err, reportEmailer.ErrScheduleInProgress) {
+		err = ereserror.New(409, err, "This schedule is already queued or running")
+	}
+	if err != nil {
+		server.Error(rw, errors.Wrap(err, frame.Function))
+		return
+	}
 
 	server.Success(rw, "test report successfully sent")
 }
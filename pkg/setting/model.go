@@ -9,6 +9,7 @@ type Settings struct {
 	EmailPort       int    `json:"senderEmailPort"`
 	EmailHost       string `json:"senderEmailHost"`
 	DatasourceID    int    `json:"datasourceID"`
+	ReportWorkers   int    `json:"reportWorkers"`
 }
 
 func SettingsFieldatasource() string {
@@ -19,5 +20,6 @@ func SettingsFieldatasource() string {
 		"\n\temailPassword string\n}" +
 		"\n\temailPort int\n}" +
 		"\n\temailHost string\n}" +
-		"\n\tDatasourceID int\n}"
+		"\n\tDatasourceID int\n}" +
+		"\n\treportWorkers int\n}"
 }
@@ -26,6 +26,7 @@ func NewSettings(ctx context.Context) (*Settings, error) {
 	senderEmailPort := jsonData.Get("senderEmailPort").MustInt()
 	senderEmailHost := jsonData.Get("senderEmailHost").MustString()
 	datasourceID := jsonData.Get("datasourceID").MustInt()
+	reportWorkers := jsonData.Get("reportWorkers").MustInt()
 
 	var grafanaPassword string
 	if securePassword, exists := pluginCxt.AppInstanceSettings.DecryptedSecureJSONData["grafanaPassword"]; exists {
@@ -43,7 +44,7 @@ func NewSettings(ctx context.Context) (*Settings, error) {
 		emailPassword = jsonData.Get("senderEmailPassword").MustString()
 	}
 
-	return &Settings{GrafanaUsername: grafanaUsername, GrafanaPassword: grafanaPassword, GrafanaURL: grafanaURL, Email: senderEmailAddress, EmailPort: senderEmailPort, EmailPassword: emailPassword, EmailHost: senderEmailHost, DatasourceID: datasourceID}, nil
+	return &Settings{GrafanaUsername: grafanaUsername, GrafanaPassword: grafanaPassword, GrafanaURL: grafanaURL, Email: senderEmailAddress, EmailPort: senderEmailPort, EmailPassword: emailPassword, EmailHost: senderEmailHost, DatasourceID: datasourceID, ReportWorkers: reportWorkers}, nil
 }
 
 func trace() *runtime.Frame {
@@ -0,0 +1,28 @@
+package validation
+
+import (
+	"time"
+
+	"excel-report-email-scheduler/pkg/datasource"
+	"excel-report-email-scheduler/pkg/ereserror"
+
+	"github.com/pkg/errors"
+)
+
+func (validator *Validation) HolidayCalendarMustBeValid(calendar datasource.HolidayCalendar) error {
+	frame := trace()
+	if calendar.Name == "" {
+		err := errors.New("holiday calendar must have a name")
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+		return err
+	}
+
+	for _, holiday := range calendar.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday.Date); err != nil {
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Holiday dates must be formatted as yyyy-mm-dd: "+holiday.Date)
+			return err
+		}
+	}
+
+	return nil
+}
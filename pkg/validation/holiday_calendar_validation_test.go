@@ -0,0 +1,33 @@
+package validation
+
+import (
+	"testing"
+
+	"excel-report-email-scheduler/pkg/datasource"
+)
+
+func TestHolidayCalendarMustBeValid(t *testing.T) {
+	validator := &Validation{}
+
+	tests := []struct {
+		name     string
+		calendar datasource.HolidayCalendar
+		wantErr  bool
+	}{
+		{name: "calendar", calendar: datasource.HolidayCalendar{Name: "Laos", Holidays: []datasource.Holiday{{Date: "2024-12-02"}}}},
+		{name: "calendar without a name", calendar: datasource.HolidayCalendar{}, wantErr: true},
+		{name: "holiday which is not yyyy-mm-dd", calendar: datasource.HolidayCalendar{Name: "Laos", Holidays: []datasource.Holiday{{Date: "02/12/2024"}}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validator.HolidayCalendarMustBeValid(test.calendar)
+			if test.wantErr && err == nil {
+				t.Error("the calendar was accepted, want an error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("the calendar was rejected: %v", err)
+			}
+		})
+	}
+}
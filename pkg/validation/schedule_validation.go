@@ -2,11 +2,15 @@ package validation
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
 
+	"excel-report-email-scheduler/pkg/api"
 	"excel-report-email-scheduler/pkg/datasource"
 	"excel-report-email-scheduler/pkg/ereserror"
 
 	"github.com/pkg/errors"
+	"github.com/robfig/cron"
 )
 
 func (validator *Validation) ScheduleDuplicates(schedule datasource.Schedule) error {
@@ -58,3 +62,240 @@ func (validator *Validation) ScheduleMustHavePanes(schedule datasource.Schedule)
 
 	return nil
 }
+
+func (validator *Validation) ScheduleCronExpressionMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	if schedule.CronExpression == "" {
+		return nil
+	}
+
+	_, err := cron.ParseStandard(schedule.CronExpression)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Invalid cron expression: "+err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (validator *Validation) ScheduleRetryPolicyMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	if schedule.RetryMaxAttempts < 0 || schedule.RetryBackoffSeconds < 0 {
+		err := errors.New("retry attempts and backoff cannot be negative")
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (validator *Validation) ScheduleCatchUpPolicyMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	switch schedule.CatchUpPolicy {
+	case "", datasource.CatchUpPolicyOnce, datasource.CatchUpPolicyAll, datasource.CatchUpPolicySkip:
+		return nil
+	}
+
+	err := errors.New("invalid catch-up policy: " + schedule.CatchUpPolicy)
+	err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+	return err
+}
+
+func (validator *Validation) ScheduleFormatMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	switch schedule.Format {
+	case "", datasource.ReportFormatXlsx, datasource.ReportFormatCsv, datasource.ReportFormatPdf:
+		return nil
+	}
+
+	err := errors.New("invalid report format: " + schedule.Format)
+	err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+	return err
+}
+
+func (validator *Validation) ScheduleLayoutMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	switch schedule.Layout {
+	case "", datasource.LayoutSheets, datasource.LayoutStacked:
+		return nil
+	}
+
+	err := errors.New("invalid layout: " + schedule.Layout)
+	err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+	return err
+}
+
+func (validator *Validation) ScheduleInlineRowsMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	if schedule.InlineRows < 0 || schedule.InlineRows > datasource.MAX_INLINE_ROWS {
+		err := fmt.Errorf("inline rows must be between 0 and %d", datasource.MAX_INLINE_ROWS)
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (validator *Validation) ScheduleTemplateMustExist(schedule datasource.Schedule) error {
+	frame := trace()
+	if schedule.TemplateID == "" {
+		return nil
+	}
+
+	var id string
+	row := validator.sqlClient.Db.QueryRow("SELECT id FROM Template WHERE id = $1 LIMIT 1", schedule.TemplateID)
+
+	switch err := row.Scan(&id); err {
+	case sql.ErrNoRows:
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Must select valid template")
+		return err
+	}
+
+	return nil
+}
+
+func (validator *Validation) ScheduleDateRangeMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	if schedule.StartDate < 0 || schedule.EndDate < 0 {
+		err := errors.New("start and end dates cannot be negative")
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+		return err
+	}
+
+	if schedule.StartDate > 0 && schedule.EndDate > 0 && schedule.EndDate < schedule.StartDate {
+		err := errors.New("end date cannot be before the start date")
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// ScheduleLookbacksMustBeValid checks the panels' lookbacks are rolling lookbacks such as
+// "now-7d" or calendar-aligned lookbacks, and that the fiscal year starts in a real month. The
+// empty lookback panels start with in the editor is let through as before.
+func (validator *Validation) ScheduleLookbacksMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	if schedule.FiscalYearStartMonth < 0 || schedule.FiscalYearStartMonth > 12 {
+		err := errors.New("fiscal year start month must be from 1 to 12, or 0 for January")
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+		return err
+	}
+
+	for _, panel := range schedule.PanelDetails {
+		if panel.Lookback == "" || api.IsCalendarLookback(panel.Lookback) || api.IsRelativeLookback(panel.Lookback) {
+			continue
+		}
+
+		err := errors.New("invalid lookback: " + panel.Lookback)
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Invalid lookback: "+panel.Lookback)
+		return err
+	}
+
+	return nil
+}
+
+func (validator *Validation) ScheduleBusinessDaysMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	switch schedule.BusinessDayRule {
+	case "", datasource.BusinessDayRuleNext, datasource.BusinessDayRulePrevious, datasource.BusinessDayRuleSkip:
+	default:
+		err := errors.New("invalid business day rule: " + schedule.BusinessDayRule)
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+		return err
+	}
+
+	if schedule.CronExpression == "" && schedule.Interval == datasource.INTERVAL_BUSINESS_DAY_OF_MONTH && (schedule.Day < 1 || schedule.Day > 23) {
+		err := errors.New("the business day of the month must be from 1 to 23")
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+		return err
+	}
+
+	if schedule.HolidayCalendarID == "" {
+		return nil
+	}
+
+	var id string
+	row := validator.sqlClient.Db.QueryRow("SELECT id FROM HolidayCalendar WHERE id = $1 LIMIT 1", schedule.HolidayCalendarID)
+
+	switch err := row.Scan(&id); err {
+	case sql.ErrNoRows:
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Must select valid holiday calendar")
+		return err
+	}
+
+	return nil
+}
+
+// ScheduleDependenciesMustBeValid checks the upstream schedules exist and that depending on
+// them would not make a schedule wait for itself.
+func (validator *Validation) ScheduleDependenciesMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	if len(schedule.DependsOn) == 0 {
+		return nil
+	}
+
+	for _, dependsOnID := range schedule.DependsOn {
+		if schedule.ID != "" && dependsOnID == schedule.ID {
+			err := errors.New("a schedule cannot depend on itself")
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+			return err
+		}
+
+		var id string
+		row := validator.sqlClient.Db.QueryRow("SELECT id FROM Schedule WHERE id = $1 LIMIT 1", dependsOnID)
+
+		switch err := row.Scan(&id); err {
+		case sql.ErrNoRows:
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), "Must select valid upstream schedules")
+			return err
+		}
+	}
+
+	// a new schedule cannot be upstream of anything yet, so it cannot be part of a cycle
+	if schedule.ID == "" {
+		return nil
+	}
+
+	dependencies, err := validator.datasource.GetAllScheduleDependencies()
+	if err != nil {
+		return err
+	}
+	dependencies[schedule.ID] = schedule.DependsOn
+
+	visited := make(map[string]bool)
+	toVisit := append([]string{}, schedule.DependsOn...)
+	for len(toVisit) > 0 {
+		id := toVisit[0]
+		toVisit = toVisit[1:]
+
+		if id == schedule.ID {
+			err := errors.New("schedule dependencies cannot form a cycle")
+			err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+			return err
+		}
+
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		toVisit = append(toVisit, dependencies[id]...)
+	}
+
+	return nil
+}
+
+func (validator *Validation) ScheduleTimeZoneMustBeValid(schedule datasource.Schedule) error {
+	frame := trace()
+	if schedule.TimeZone == "" {
+		return nil
+	}
+
+	_, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Invalid time zone: "+schedule.TimeZone)
+		return err
+	}
+
+	return nil
+}
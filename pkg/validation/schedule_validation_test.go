@@ -0,0 +1,80 @@
+package validation
+
+import (
+	"testing"
+
+	"excel-report-email-scheduler/pkg/datasource"
+)
+
+// The validators tested here do not need the database, so they are run on an empty Validation.
+func TestScheduleValidators(t *testing.T) {
+	validator := &Validation{}
+
+	withLookback := func(lookback string) datasource.Schedule {
+		return datasource.Schedule{PanelDetails: []datasource.ReportContent{{Lookback: lookback}}}
+	}
+
+	tests := []struct {
+		name     string
+		validate func(datasource.Schedule) error
+		schedule datasource.Schedule
+		wantErr  bool
+	}{
+		{name: "lookback left empty", validate: validator.ScheduleLookbacksMustBeValid, schedule: withLookback("")},
+		{name: "relative lookback", validate: validator.ScheduleLookbacksMustBeValid, schedule: withLookback("now-7d")},
+		{name: "relative lookback in months", validate: validator.ScheduleLookbacksMustBeValid, schedule: withLookback("now-3M")},
+		{name: "calendar lookback", validate: validator.ScheduleLookbacksMustBeValid, schedule: withLookback("previousMonth")},
+		{name: "misspelt calendar lookback", validate: validator.ScheduleLookbacksMustBeValid, schedule: withLookback("previousMnth"), wantErr: true},
+		{name: "lookback which is not a time", validate: validator.ScheduleLookbacksMustBeValid, schedule: withLookback("bogus"), wantErr: true},
+		{name: "relative lookback without a unit", validate: validator.ScheduleLookbacksMustBeValid, schedule: withLookback("now-7"), wantErr: true},
+		{name: "relative lookback into the future", validate: validator.ScheduleLookbacksMustBeValid, schedule: withLookback("now+1d"), wantErr: true},
+		{name: "fiscal year starting in month 0", validate: validator.ScheduleLookbacksMustBeValid, schedule: datasource.Schedule{FiscalYearStartMonth: 0}},
+		{name: "fiscal year starting in December", validate: validator.ScheduleLookbacksMustBeValid, schedule: datasource.Schedule{FiscalYearStartMonth: 12}},
+		{name: "fiscal year starting in month 13", validate: validator.ScheduleLookbacksMustBeValid, schedule: datasource.Schedule{FiscalYearStartMonth: 13}, wantErr: true},
+		{name: "fiscal year starting in a negative month", validate: validator.ScheduleLookbacksMustBeValid, schedule: datasource.Schedule{FiscalYearStartMonth: -1}, wantErr: true},
+
+		{name: "no cron expression", validate: validator.ScheduleCronExpressionMustBeValid, schedule: datasource.Schedule{}},
+		{name: "cron expression", validate: validator.ScheduleCronExpressionMustBeValid, schedule: datasource.Schedule{CronExpression: "0 9 * * 1-5"}},
+		{name: "invalid cron expression", validate: validator.ScheduleCronExpressionMustBeValid, schedule: datasource.Schedule{CronExpression: "0 25 * * *"}, wantErr: true},
+
+		{name: "no time zone", validate: validator.ScheduleTimeZoneMustBeValid, schedule: datasource.Schedule{}},
+		{name: "time zone", validate: validator.ScheduleTimeZoneMustBeValid, schedule: datasource.Schedule{TimeZone: "Pacific/Auckland"}},
+		{name: "unknown time zone", validate: validator.ScheduleTimeZoneMustBeValid, schedule: datasource.Schedule{TimeZone: "Mars/Olympus_Mons"}, wantErr: true},
+
+		{name: "retry policy", validate: validator.ScheduleRetryPolicyMustBeValid, schedule: datasource.Schedule{RetryMaxAttempts: 3, RetryBackoffSeconds: 60}},
+		{name: "negative retry attempts", validate: validator.ScheduleRetryPolicyMustBeValid, schedule: datasource.Schedule{RetryMaxAttempts: -1}, wantErr: true},
+
+		{name: "catch-up policy", validate: validator.ScheduleCatchUpPolicyMustBeValid, schedule: datasource.Schedule{CatchUpPolicy: datasource.CatchUpPolicyAll}},
+		{name: "unknown catch-up policy", validate: validator.ScheduleCatchUpPolicyMustBeValid, schedule: datasource.Schedule{CatchUpPolicy: "some"}, wantErr: true},
+
+		{name: "date range", validate: validator.ScheduleDateRangeMustBeValid, schedule: datasource.Schedule{StartDate: 100, EndDate: 200}},
+		{name: "open ended date range", validate: validator.ScheduleDateRangeMustBeValid, schedule: datasource.Schedule{StartDate: 100}},
+		{name: "date range ending before it starts", validate: validator.ScheduleDateRangeMustBeValid, schedule: datasource.Schedule{StartDate: 200, EndDate: 100}, wantErr: true},
+
+		{name: "business day rule", validate: validator.ScheduleBusinessDaysMustBeValid, schedule: datasource.Schedule{BusinessDayRule: datasource.BusinessDayRuleNext}},
+		{name: "unknown business day rule", validate: validator.ScheduleBusinessDaysMustBeValid, schedule: datasource.Schedule{BusinessDayRule: "later"}, wantErr: true},
+		{name: "business day of the month", validate: validator.ScheduleBusinessDaysMustBeValid, schedule: datasource.Schedule{Interval: datasource.INTERVAL_BUSINESS_DAY_OF_MONTH, Day: 23}},
+		{name: "business day of the month past 23", validate: validator.ScheduleBusinessDaysMustBeValid, schedule: datasource.Schedule{Interval: datasource.INTERVAL_BUSINESS_DAY_OF_MONTH, Day: 24}, wantErr: true},
+
+		{name: "report format", validate: validator.ScheduleFormatMustBeValid, schedule: datasource.Schedule{Format: datasource.ReportFormatPdf}},
+		{name: "unknown report format", validate: validator.ScheduleFormatMustBeValid, schedule: datasource.Schedule{Format: "docx"}, wantErr: true},
+
+		{name: "inline rows", validate: validator.ScheduleInlineRowsMustBeValid, schedule: datasource.Schedule{InlineRows: datasource.MAX_INLINE_ROWS}},
+		{name: "too many inline rows", validate: validator.ScheduleInlineRowsMustBeValid, schedule: datasource.Schedule{InlineRows: datasource.MAX_INLINE_ROWS + 1}, wantErr: true},
+
+		{name: "layout", validate: validator.ScheduleLayoutMustBeValid, schedule: datasource.Schedule{Layout: datasource.LayoutStacked}},
+		{name: "unknown layout", validate: validator.ScheduleLayoutMustBeValid, schedule: datasource.Schedule{Layout: "grid"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.validate(test.schedule)
+			if test.wantErr && err == nil {
+				t.Error("the schedule was accepted, want an error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("the schedule was rejected: %v", err)
+			}
+		})
+	}
+}
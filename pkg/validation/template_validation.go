@@ -0,0 +1,37 @@
+package validation
+
+import (
+	"database/sql"
+
+	"excel-report-email-scheduler/pkg/datasource"
+	"excel-report-email-scheduler/pkg/ereserror"
+
+	"github.com/pkg/errors"
+)
+
+func (validator *Validation) TemplateMustBeValid(template datasource.Template) error {
+	frame := trace()
+	if template.Name == "" {
+		err := errors.New("template must have a name")
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), err.Error())
+		return err
+	}
+
+	var id string
+	row := validator.sqlClient.Db.QueryRow("SELECT id FROM Template WHERE name = $1 LIMIT 1", template.Name)
+	err := row.Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		err = ereserror.New(500, errors.Wrap(err, frame.Function), "Could not check the template's name")
+		return err
+	}
+
+	if id != template.ID {
+		err = ereserror.New(500, errors.Wrap(errors.New("duplicate template name"), frame.Function), "Cannot have more than one template with same name")
+		return err
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ import { getBackendSrv } from '@grafana/runtime';
 import { DashboardMeta, DashboardResponse, Panel, SelectableVariable, Variable } from 'types';
 import { panelUsesUnsupportedMacro, panelUsesVariable } from 'utils/checkers.utils';
 import { getDatasource } from './getDatasource.api';
+import { REPORTABLE_PANEL_TYPES } from '../constants';
 
 export const getPanels = async (datasourceID: number): Promise<Panel[]> => {
   const dashboards = (await getDashboards()) ?? [];
@@ -14,7 +15,7 @@ export const getPanels = async (datasourceID: number): Promise<Panel[]> => {
     .filter(({ panels }) => panels?.length > 0)
     .map(({ panels, templating, uid }) => {
       const mappedPanels = panels
-        .filter(({ type }) => type === 'table' || type === 'table-old' || type === 'msupplyfoundation-table')
+        .filter(({ type }) => REPORTABLE_PANEL_TYPES.includes(type))
         .map((rawPanel) => {
           const { targets } = rawPanel;
           const [target] = targets;
@@ -11,6 +11,10 @@ export enum ROUTES {
   SCHEDULES = 'schedules',
 }
 
+// Tables are reported as they are, while time series and bar charts are reported as their
+// data along with a native Excel chart.
+export const REPORTABLE_PANEL_TYPES = ['table', 'table-old', 'msupplyfoundation-table', 'timeseries', 'barchart'];
+
 export const NAVIGATION_TITLE = 'Excel report e-mail scheduler';
 export const NAVIGATION_SUBTITLE = `Generate Excel reports from mSupply dashboard. Send the reports to custom created user-groups on pre-defined schedule.`;
 
@@ -61,4 +65,12 @@ export const getLookbacks = (): Array<SelectableValue<String>> => [
   { label: intl.get('3months'), value: 'now-3M' },
   { label: intl.get('6months'), value: 'now-6M' },
   { label: intl.get('1year'), value: 'now-1y' },
+  { label: intl.get('previousDay'), value: 'previousDay' },
+  { label: intl.get('previousWeek'), value: 'previousWeek' },
+  { label: intl.get('previousMonth'), value: 'previousMonth' },
+  { label: intl.get('previousQuarter'), value: 'previousQuarter' },
+  { label: intl.get('previousYear'), value: 'previousYear' },
+  { label: intl.get('monthToDate'), value: 'monthToDate' },
+  { label: intl.get('previousFiscalYear'), value: 'previousFiscalYear' },
+  { label: intl.get('fiscalYearToDate'), value: 'fiscalYearToDate' },
 ];